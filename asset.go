@@ -0,0 +1,280 @@
+package offline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KindImage    = "image"
+	KindStyle    = "style"
+	KindScript   = "script"
+	KindFont     = "font"
+	KindMedia    = "media"
+	KindDocument = "document"
+	KindOther    = "other"
+)
+
+// Asset is a single entry in a cache list along with details about where it
+// was found.
+type Asset struct {
+	// URL is the resolved cache list entry.
+	URL string `json:"url"`
+	// Value is the value URL was derived from, as it appears in the source.
+	Value     string `json:"value"`
+	Element   string `json:"element,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	// Kind is one of: image, style, script, font, media, document, other.
+	Kind        string `json:"kind"`
+	CrossOrigin bool   `json:"cross_origin"`
+	// Source is the URL of the document, stylesheet, manifest or module that
+	// Value was found in. It may be empty.
+	Source string `json:"source,omitempty"`
+	// Line is the line number in Source that Value was found on or zero if it
+	// is unknown.
+	Line int `json:"line,omitempty"`
+	// Revision is a hash of the asset's contents if it is a local file. It
+	// may be empty.
+	Revision string `json:"revision,omitempty"`
+}
+
+// Inventory is the list of assets for a document, in cache list order.
+type Inventory struct {
+	// Origin is the origin (scheme and host) of the service worker's scope.
+	// It may be empty.
+	Origin string   `json:"origin,omitempty"`
+	Assets []*Asset `json:"assets"`
+	// Fallbacks maps request destinations (document, image, font and so on)
+	// to the cache list entry served when a request can't otherwise be
+	// fulfilled.
+	Fallbacks map[string]string `json:"fallbacks,omitempty"`
+	// scope is the URL relative cache list entries are written against.
+	scope *url.URL
+}
+
+// URLs returns the cache list entry for each asset in the inventory.
+func (i *Inventory) URLs() []string {
+
+	urls := make([]string, len(i.Assets))
+
+	for idx, a := range i.Assets {
+		urls[idx] = a.URL
+	}
+
+	return urls
+}
+
+// PrecacheRequests returns the list of PrecacheRequest instances for the
+// assets in the inventory. See PrecacheRequests for details.
+func (i *Inventory) PrecacheRequests(opts *ServiceWorkerOptions) []*PrecacheRequest {
+
+	kinds := make(map[string]string)
+
+	for _, a := range i.Assets {
+		kinds[a.URL] = a.Kind
+	}
+
+	requests := PrecacheRequests(i.URLs(), i.Origin, opts)
+
+	revisions := make(map[string]string)
+
+	for _, a := range i.Assets {
+		revisions[a.URL] = a.Revision
+	}
+
+	for _, r := range requests {
+		r.Kind = kinds[r.URL]
+		r.Revision = revisions[r.URL]
+	}
+
+	return requests
+}
+
+func IsValidKind(kind string) bool {
+
+	switch kind {
+	case KindImage, KindStyle, KindScript, KindFont, KindMedia, KindDocument, KindOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// Version returns a short hash of the URLs and revisions of the assets in the
+// inventory. It changes whenever an asset is added or removed or a local file
+// is updated.
+func (i *Inventory) Version() string {
+
+	h := sha256.New()
+
+	for _, a := range i.Assets {
+		io.WriteString(h, fmt.Sprintf("%s %s\n", a.URL, a.Revision))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// ScopeID returns a short hash of the URL of the service worker's scope. It
+// returns an empty string if the scope is unknown.
+func (i *Inventory) ScopeID() string {
+
+	if i.scope == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	io.WriteString(h, i.scope.String())
+
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// setRevisions assigns a revision to each asset which is a local file.
+func (i *Inventory) setRevisions() {
+
+	for _, a := range i.Assets {
+
+		path, ok := i.localFile(a)
+
+		if !ok {
+			continue
+		}
+
+		revision, err := fileRevision(path)
+
+		if err != nil {
+			continue
+		}
+
+		a.Revision = revision
+	}
+}
+
+func fileRevision(path string) (string, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer fh.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, fh)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// localFile returns the path on the local filesystem for the asset a, if the
+// inventory is for a local file and a is a (regular) file relative to it.
+func (i *Inventory) localFile(a *Asset) (string, bool) {
+
+	if i.scope == nil || i.scope.Scheme != "file" {
+		return "", false
+	}
+
+	u, err := url.Parse(a.URL)
+
+	if err != nil || u.IsAbs() || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+
+	path := filepath.FromSlash(i.scope.ResolveReference(u).Path)
+
+	info, err := os.Stat(path)
+
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	return path, true
+}
+
+// InferKind returns the kind of asset that uri, derived from the attribute
+// of an HTML element, is likely to be. element and attribute may be empty in
+// which case the kind is determined by the file extension of uri. If nothing
+// else matches KindOther is returned.
+func InferKind(element string, attribute string, uri string) string {
+
+	switch element {
+	case "img", "image", "picture":
+		return KindImage
+	case "script":
+		return KindScript
+	case "iframe", "frame":
+		return KindDocument
+	}
+
+	if attribute == "poster" || attribute == "data-poster" {
+		return KindImage
+	}
+
+	kind := extensionKind(uri)
+
+	if kind != "" {
+		return kind
+	}
+
+	switch element {
+	case "video", "audio", "track":
+		return KindMedia
+	case "source":
+
+		if attribute == "srcset" {
+			return KindImage
+		}
+
+		return KindMedia
+
+	case "input", "use":
+		return KindImage
+	}
+
+	// url() references in inline styles are most likely images
+
+	if element == "style" || attribute == "style" {
+		return KindImage
+	}
+
+	return KindOther
+}
+
+func extensionKind(uri string) string {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return ""
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+
+	switch ext {
+	case "apng", "avif", "bmp", "gif", "ico", "jpeg", "jpg", "png", "svg", "tif", "tiff", "webp":
+		return KindImage
+	case "css":
+		return KindStyle
+	case "js", "mjs":
+		return KindScript
+	case "eot", "otf", "ttf", "woff", "woff2":
+		return KindFont
+	case "aac", "flac", "m4a", "m4v", "mov", "mp3", "mp4", "oga", "ogg", "ogv", "vtt", "wav", "webm":
+		return KindMedia
+	case "htm", "html":
+		return KindDocument
+	default:
+		return ""
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"flag"
 	"github.com/sfomuseum/go-html-offline"
+	offline_flags "github.com/sfomuseum/go-html-offline/flags"
 	"github.com/whosonfirst/go-whosonfirst-cli/flags"
 	"github.com/whosonfirst/walk"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -14,17 +16,187 @@ func main() {
 
 	cache_name := flag.String("cache-name", "network-or-cache", "The name for your browser/service worker cache.")
 	sw_url := flag.String("server-worker-url", "sw.js", "The URI of the JavaScript service worker.")
+	version_cache := flag.Bool("version-cache", true, "Append a version to the cache name and delete the caches of earlier versions when the service worker is activated.")
+	cache_version := flag.String("cache-version", "", "The version to append to the cache name. Default is a hash of the cache list (and the contents of local files).")
+	keep_versions := flag.Int("keep-versions", 1, "The number of cache versions, including the current one, to keep when the service worker is activated.")
+	skip_waiting := flag.Bool("skip-waiting", false, "Activate a new service worker as soon as it is installed rather than waiting for every page using the old one to close.")
+	clients_claim := flag.Bool("clients-claim", false, "Take control of open pages as soon as the service worker is activated.")
+	navigation_preload := flag.Bool("navigation-preload", false, "Enable navigation preload, fetching pages in parallel with the service worker starting up, where it is supported.")
+	fallback_document := flag.String("fallback-document", "", "The path to a local HTML file (or a URI) to serve for navigation requests when both the network and the cache fail.")
+	fallback_image := flag.String("fallback-image", "", "The path to a local image file (or a URI) to serve for image requests when both the network and the cache fail.")
+	fallback_font := flag.String("fallback-font", "", "The path to a local font file (or a URI) to serve for font requests when both the network and the cache fail.")
+	sw_template := flag.String("sw-template", "", "The path to a custom (Go) template for the service worker JavaScript.")
+	init_template := flag.String("init-template", "", "The path to a custom (Go) template for the JavaScript that registers the service worker.")
+	precache_mode := flag.String("precache-mode", offline.PrecacheStrict, "How to precache the cache list. Valid options are: strict (a single failure fails the install), tolerant (failures are retried and then reported to open pages).")
+	precache_retries := flag.Int("precache-retries", 3, "The number of times to retry a failed request in tolerant precache mode.")
+	precache_retry_delay := flag.Int("precache-retry-delay", 500, "The number of milliseconds to wait before retrying a failed request in tolerant precache mode. The delay doubles for each retry.")
+	strategy := flag.String("strategy", offline.StrategyNetworkFirst, "The caching strategy for the service worker. Valid options are: cache-first, network-first, stale-while-revalidate, cache-only, network-only.")
+	network_timeout := flag.Int("network-timeout", 400, "The number of milliseconds to wait for a network response, using the network-first strategy, before falling back to the cache. Zero means no timeout.")
+	runtime_caching := flag.Bool("runtime-caching", false, "Store the responses for requests that aren't in the cache list in a runtime cache.")
+	runtime_cache_name := flag.String("runtime-cache-name", "runtime", "The name of the runtime cache.")
+	runtime_max_entries := flag.Int("runtime-max-entries", 100, "The maximum number of entries in the runtime cache. Least recently used entries are evicted first. Zero means no limit.")
+	runtime_max_age := flag.Int("runtime-max-age", 60*60*24*30, "The maximum age, in seconds, of the entries in the runtime cache. Zero means no limit.")
+	noscript := flag.Bool("noscript", true, "Parse the contents of <noscript> elements for URLs.")
+	templates := flag.Bool("templates", true, "Parse the contents of <template> elements for URLs.")
+	lazy_load := flag.Bool("lazy-load", true, "Derive URLs from common lazy-loading attributes (data-src, data-srcset, etc.)")
+	skip_print := flag.Bool("skip-print-stylesheets", false, "Exclude stylesheets whose media attribute is \"print\".")
+	skip_alternate := flag.Bool("skip-alternate-stylesheets", false, "Exclude \"alternate stylesheet\" stylesheets.")
+	follow_frames := flag.Bool("follow-frames", false, "Inventory the contents of same-origin <iframe> elements.")
+	metadata := flag.Bool("metadata", false, "Derive URLs from Open Graph, Twitter card and JSON-LD metadata.")
 	mode := flag.String("mode", "file", "Indicate how command line arguments should be interpreted. Valid options are: files, directory.")
 
 	var urls flags.MultiString
 	flag.Var(&urls, "url", "One or more URLs to append to the service worker cache list")
 
+	var extract flags.MultiString
+	flag.Var(&extract, "extract", "One or more extractor rules (for example \"element=div attr=data-bg\") for deriving additional URLs from HTML elements")
+
+	var routes flags.MultiString
+	flag.Var(&routes, "route", "One or more route rules (for example \"kind=image strategy=cache-first\") for handling requests. Routes are evaluated in order.")
+
+	var template_data flags.MultiString
+	flag.Var(&template_data, "template-data", "One or more key=value pairs to make available to templates as .Data.{key}")
+
+	list_flags := offline_flags.AppendCacheListFlags(flag.CommandLine)
+
 	flag.Parse()
 
+	if *keep_versions < 1 {
+		log.Fatal("Invalid -keep-versions")
+	}
+
+	if !offline.IsValidPrecacheMode(*precache_mode) {
+		log.Fatal("Invalid -precache-mode")
+	}
+
+	if *precache_retries < 0 || *precache_retry_delay < 0 {
+		log.Fatal("Invalid -precache-retries or -precache-retry-delay")
+	}
+
+	if !offline.IsValidStrategy(*strategy) {
+		log.Fatal("Invalid -strategy")
+	}
+
+	if *network_timeout < 0 {
+		log.Fatal("Invalid -network-timeout")
+	}
+
+	if *runtime_cache_name == "" || *runtime_max_entries < 0 || *runtime_max_age < 0 {
+		log.Fatal("Invalid -runtime-cache-name, -runtime-max-entries or -runtime-max-age")
+	}
+
 	opts := offline.DefaultServiceWorkerOptions()
 	opts.CacheName = *cache_name
+	opts.PrecacheMode = *precache_mode
+	opts.PrecacheRetries = *precache_retries
+	opts.PrecacheRetryDelay = *precache_retry_delay
+	opts.VersionCache = *version_cache
+	opts.CacheVersion = *cache_version
+	opts.KeepVersions = *keep_versions
+	opts.SkipWaiting = *skip_waiting
+	opts.ClientsClaim = *clients_claim
+	opts.NavigationPreload = *navigation_preload
+	opts.Strategy = *strategy
+	opts.NetworkTimeout = *network_timeout
+	opts.RuntimeCaching = *runtime_caching
+	opts.RuntimeCacheName = *runtime_cache_name
+	opts.RuntimeMaxEntries = *runtime_max_entries
+	opts.RuntimeMaxAge = *runtime_max_age
 	opts.CacheURLs = urls
 	opts.ServiceWorkerURL = *sw_url
+	opts.Noscript = *noscript
+	opts.Templates = *templates
+	opts.LazyLoad = *lazy_load
+	opts.FollowFrames = *follow_frames
+	opts.Metadata = *metadata
+	opts.SkipPrintStylesheets = *skip_print
+	opts.SkipAlternateStylesheets = *skip_alternate
+
+	err := list_flags.Apply(opts)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fallbacks := map[string]string{
+		"document": *fallback_document,
+		"image":    *fallback_image,
+		"font":     *fallback_font,
+	}
+
+	for dest, uri := range fallbacks {
+
+		if uri == "" {
+			continue
+		}
+
+		// local files are passed along as absolute paths so that they can be
+		// written relative to each document's service worker
+
+		_, err := os.Stat(uri)
+
+		if err == nil {
+
+			abs_path, err := filepath.Abs(uri)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			uri = abs_path
+		}
+
+		opts.Fallbacks[dest] = uri
+	}
+
+	if *sw_template != "" {
+		opts.ServiceWorkerTemplate = offline.NewTemplateFromFile(*sw_template)
+	}
+
+	if *init_template != "" {
+		opts.InitTemplate = offline.NewTemplateFromFile(*init_template)
+	}
+
+	for _, kv := range template_data {
+
+		parts := strings.SplitN(kv, "=", 2)
+
+		if len(parts) != 2 {
+			log.Fatal("Invalid -template-data")
+		}
+
+		opts.TemplateData[parts[0]] = parts[1]
+	}
+
+	// make sure templates are valid before any files are written
+
+	err = offline.ValidateTemplates(opts)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, str_route := range routes {
+
+		route, err := offline.ParseRoute(str_route)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts.Routes = append(opts.Routes, route)
+	}
+
+	for _, str_rule := range extract {
+
+		rule, err := offline.ParseExtractorRule(str_rule)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts.Extractors = append(opts.Extractors, rule)
+	}
 
 	switch *mode {
 
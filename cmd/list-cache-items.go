@@ -1,13 +1,18 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"github.com/sfomuseum/go-html-offline"
+	offline_flags "github.com/sfomuseum/go-html-offline/flags"
 	"github.com/whosonfirst/go-whosonfirst-cli/flags"
 	"github.com/whosonfirst/walk"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -15,15 +20,63 @@ import (
 func main() {
 
 	mode := flag.String("mode", "file", "Indicate how command line arguments should be interpreted. Valid options are: files, directory.")
+	scope := flag.String("scope", "", "The URI, relative to each document, that relative cache entries are written against. Default is the directory of the service worker URL.")
+	absolute_urls := flag.Bool("absolute-urls", false, "Write cache entries as absolute URLs rather than relative to the service worker's scope.")
+	noscript := flag.Bool("noscript", true, "Parse the contents of <noscript> elements for URLs.")
+	templates := flag.Bool("templates", true, "Parse the contents of <template> elements for URLs.")
+	lazy_load := flag.Bool("lazy-load", true, "Derive URLs from common lazy-loading attributes (data-src, data-srcset, etc.)")
+	skip_print := flag.Bool("skip-print-stylesheets", false, "Exclude stylesheets whose media attribute is \"print\".")
+	skip_alternate := flag.Bool("skip-alternate-stylesheets", false, "Exclude \"alternate stylesheet\" stylesheets.")
+	follow_frames := flag.Bool("follow-frames", false, "Inventory the contents of same-origin <iframe> elements.")
+	metadata := flag.Bool("metadata", false, "Derive URLs from Open Graph, Twitter card and JSON-LD metadata.")
+	format := flag.String("format", "text", "The format to list cache items in. Valid options are: text, json, csv.")
 	validate := flag.Bool("validate", false, "...")
 
 	var urls flags.MultiString
 	flag.Var(&urls, "url", "One or more URLs to append to the service worker cache list")
 
+	var extract flags.MultiString
+	flag.Var(&extract, "extract", "One or more extractor rules (for example \"element=div attr=data-bg\") for deriving additional URLs from HTML elements")
+
+	list_flags := offline_flags.AppendCacheListFlags(flag.CommandLine)
+
 	flag.Parse()
 
+	switch *format {
+	case "text", "json", "csv":
+		// pass
+	default:
+		log.Fatal("Invalid -format")
+	}
+
 	opts := offline.DefaultServiceWorkerOptions()
 	opts.CacheURLs = urls
+	opts.Scope = *scope
+	opts.AbsoluteURLs = *absolute_urls
+	opts.Noscript = *noscript
+	opts.Templates = *templates
+	opts.LazyLoad = *lazy_load
+	opts.FollowFrames = *follow_frames
+	opts.Metadata = *metadata
+	opts.SkipPrintStylesheets = *skip_print
+	opts.SkipAlternateStylesheets = *skip_alternate
+
+	err := list_flags.Apply(opts)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, str_rule := range extract {
+
+		rule, err := offline.ParseExtractorRule(str_rule)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts.Extractors = append(opts.Extractors, rule)
+	}
 
 	items := new(sync.Map)
 
@@ -45,7 +98,7 @@ func main() {
 				return nil
 			}
 
-			cache, err := offline.CacheListFromFile(path, opts)
+			cache, err := offline.CacheInventoryFromFile(path, opts)
 
 			if err != nil {
 				return err
@@ -68,7 +121,7 @@ func main() {
 
 		for _, path := range flag.Args() {
 
-			cache, err := offline.CacheListFromFile(path, opts)
+			cache, err := offline.CacheInventoryFromFile(path, opts)
 
 			if err != nil {
 				log.Fatal(err)
@@ -81,7 +134,7 @@ func main() {
 
 		for _, url := range flag.Args() {
 
-			cache, err := offline.CacheListFromURL(url, opts)
+			cache, err := offline.CacheInventoryFromURL(url, opts)
 
 			if err != nil {
 				log.Fatal(err)
@@ -94,19 +147,80 @@ func main() {
 		log.Fatal("Invalid -mode")
 	}
 
+	inventories := make(map[string]*offline.Inventory)
+	documents := make([]string, 0)
+
 	items.Range(func(key interface{}, value interface{}) bool {
 
 		uri := key.(string)
-		cache := value.([]string)
+		inventories[uri] = value.(*offline.Inventory)
+		documents = append(documents, uri)
+
+		return true
+	})
+
+	sort.Strings(documents)
+
+	switch *format {
 
-		log.Println(uri)
+	case "json":
 
-		for _, u := range cache {
-			log.Println(u)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		err := enc.Encode(inventories)
+
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		return true
-	})
+	case "csv":
+
+		wr := csv.NewWriter(os.Stdout)
+
+		header := []string{"document", "url", "kind", "origin", "element", "attribute", "value", "source", "line", "revision"}
+		wr.Write(header)
+
+		for _, uri := range documents {
+
+			for _, a := range inventories[uri].Assets {
+
+				origin := "same-origin"
+
+				if a.CrossOrigin {
+					origin = "cross-origin"
+				}
+
+				line := ""
+
+				if a.Line > 0 {
+					line = strconv.Itoa(a.Line)
+				}
+
+				row := []string{uri, a.URL, a.Kind, origin, a.Element, a.Attribute, a.Value, a.Source, line, a.Revision}
+				wr.Write(row)
+			}
+		}
+
+		wr.Flush()
+
+		err := wr.Error()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+
+		for _, uri := range documents {
+
+			log.Println(uri)
+
+			for _, u := range inventories[uri].URLs() {
+				log.Println(u)
+			}
+		}
+	}
 
 	if *validate {
 
@@ -114,9 +228,9 @@ func main() {
 
 		items.Range(func(key interface{}, value interface{}) bool {
 
-			cache := value.([]string)
+			inventory := value.(*offline.Inventory)
 
-			for _, u := range cache {
+			for _, u := range inventory.URLs() {
 
 				if strings.HasPrefix(u, "http") {
 					to_validate.Store(u, true)
@@ -0,0 +1,163 @@
+package offline
+
+import (
+	"net/url"
+	"strings"
+)
+
+const (
+	PrecacheStrict   = "strict"
+	PrecacheTolerant = "tolerant"
+)
+
+const (
+	OpaqueCache = "cache"
+	OpaqueSkip  = "skip"
+	OpaqueDefer = "defer"
+)
+
+// PrecacheRequest describes how the service worker should request a single
+// entry in the cache list.
+type PrecacheRequest struct {
+	URL         string
+	CrossOrigin bool
+	// Kind is the kind of asset (see InferKind). It may be empty.
+	Kind string
+	// Revision is a hash of the asset's contents. It may be empty in which
+	// case the entry is always fetched when the service worker is installed.
+	Revision string
+	// Mode is the Request mode: cors or no-cors.
+	Mode string
+	// Credentials is the Request credentials mode: omit, same-origin or include.
+	Credentials string
+	// Opaque is true if the response will be opaque (a no-cors request for a
+	// cross-origin URL). Opaque responses can not be added using cache.addAll.
+	Opaque bool
+	// Defer is true if the entry should be cached after the service worker
+	// has been installed rather than blocking installation.
+	Defer bool
+}
+
+func IsValidPrecacheMode(mode string) bool {
+
+	switch mode {
+	case PrecacheStrict, PrecacheTolerant:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidCrossOriginMode(mode string) bool {
+
+	switch mode {
+	case "cors", "no-cors":
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidCredentials(credentials string) bool {
+
+	switch credentials {
+	case "omit", "same-origin", "include":
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidOpaquePolicy(policy string) bool {
+
+	switch policy {
+	case OpaqueCache, OpaqueSkip, OpaqueDefer:
+		return true
+	default:
+		return false
+	}
+}
+
+// PrecacheRequests classifies each URI in uris as same-origin or cross-origin
+// and returns the corresponding list of PrecacheRequest instances. self is the
+// origin (scheme and host) that relative URIs belong to; it may be empty in
+// which case only relative URIs are considered same-origin. Cross-origin
+// requests use mode "cors" if their origin is listed in opts.CORSOrigins and
+// opts.CrossOriginMode otherwise. Opaque entries are handled according to
+// opts.OpaquePolicy.
+func PrecacheRequests(uris []string, self string, opts *ServiceWorkerOptions) []*PrecacheRequest {
+
+	requests := make([]*PrecacheRequest, 0)
+
+	for _, uri := range uris {
+
+		r := &PrecacheRequest{
+			URL:         uri,
+			Mode:        "cors",
+			Credentials: "same-origin",
+		}
+
+		origin := uriOrigin(uri, self)
+
+		if origin != "" && !strings.EqualFold(origin, self) {
+
+			r.CrossOrigin = true
+			r.Credentials = opts.CrossOriginCredentials
+
+			if r.Credentials == "" {
+				r.Credentials = "omit"
+			}
+
+			if !hasOrigin(opts.CORSOrigins, origin) {
+				r.Mode = opts.CrossOriginMode
+			}
+
+			if r.Mode == "" {
+				r.Mode = "no-cors"
+			}
+		}
+
+		r.Opaque = r.CrossOrigin && r.Mode == "no-cors"
+
+		if r.Opaque {
+
+			switch opts.OpaquePolicy {
+			case OpaqueSkip:
+				continue
+			case OpaqueDefer:
+				r.Defer = true
+			}
+		}
+
+		requests = append(requests, r)
+	}
+
+	return requests
+}
+
+// uriOrigin returns the origin (scheme and host) of uri. Relative URIs belong
+// to self and protocol-relative URIs use the scheme of self or, if self is
+// empty, https (since service workers require a secure context).
+func uriOrigin(uri string, self string) string {
+
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Host == "" {
+		return self
+	}
+
+	scheme := u.Scheme
+
+	if scheme == "" {
+
+		scheme = "https"
+
+		self_u, err := url.Parse(self)
+
+		if err == nil && self_u.Scheme != "" {
+			scheme = self_u.Scheme
+		}
+	}
+
+	return strings.ToLower(scheme + "://" + u.Host)
+}
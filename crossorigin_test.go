@@ -0,0 +1,88 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestURIOrigin(t *testing.T) {
+
+	tests := []struct {
+		uri      string
+		self     string
+		expected string
+	}{
+		{"./a.png", "https://example.com", "https://example.com"},
+		{"/a.png", "https://example.com", "https://example.com"},
+		{"./a.png", "", ""},
+		{"https://CDN.example.com/a.png", "https://example.com", "https://cdn.example.com"},
+		{"http://cdn.example.com:8080/a.png", "https://example.com", "http://cdn.example.com:8080"},
+		{"//cdn.example.com/a.png", "https://example.com", "https://cdn.example.com"},
+		{"//cdn.example.com/a.png", "http://localhost:8080", "http://cdn.example.com"},
+		{"//cdn.example.com/a.png", "", "https://cdn.example.com"},
+	}
+
+	for _, test := range tests {
+
+		origin := uriOrigin(test.uri, test.self)
+
+		if origin != test.expected {
+			t.Fatalf("Expected origin of %s (relative to '%s') to be '%s', got '%s'", test.uri, test.self, test.expected, origin)
+		}
+	}
+}
+
+func TestPrecacheRequests(t *testing.T) {
+
+	self := "https://example.com"
+
+	tests := []struct {
+		uri         string
+		cors        []string
+		mode        string
+		credentials string
+		policy      string
+		expected    *PrecacheRequest
+	}{
+		{"./a.png", nil, "no-cors", "omit", OpaqueCache, &PrecacheRequest{Mode: "cors", Credentials: "same-origin"}},
+		{"https://example.com/a.png", nil, "no-cors", "omit", OpaqueCache, &PrecacheRequest{Mode: "cors", Credentials: "same-origin"}},
+		{"https://cdn.example.com/a.png", nil, "no-cors", "omit", OpaqueCache, &PrecacheRequest{CrossOrigin: true, Mode: "no-cors", Credentials: "omit", Opaque: true}},
+		{"https://cdn.example.com/a.png", nil, "no-cors", "include", OpaqueDefer, &PrecacheRequest{CrossOrigin: true, Mode: "no-cors", Credentials: "include", Opaque: true, Defer: true}},
+		{"https://cdn.example.com/a.png", nil, "no-cors", "omit", OpaqueSkip, nil},
+		{"https://cdn.example.com/a.png", nil, "cors", "omit", OpaqueSkip, &PrecacheRequest{CrossOrigin: true, Mode: "cors", Credentials: "omit"}},
+		{"https://cdn.example.com/a.png", []string{"https://cdn.example.com"}, "no-cors", "omit", OpaqueSkip, &PrecacheRequest{CrossOrigin: true, Mode: "cors", Credentials: "omit"}},
+		{"https://cdn.example.com/a.png", []string{"https://CDN.example.com/"}, "no-cors", "same-origin", OpaqueDefer, &PrecacheRequest{CrossOrigin: true, Mode: "cors", Credentials: "same-origin"}},
+		{"//cdn.example.com/a.png", []string{"https://cdn.example.com"}, "no-cors", "omit", OpaqueDefer, &PrecacheRequest{CrossOrigin: true, Mode: "cors", Credentials: "omit"}},
+		{"//cdn.example.com/a.png", nil, "no-cors", "omit", OpaqueDefer, &PrecacheRequest{CrossOrigin: true, Mode: "no-cors", Credentials: "omit", Opaque: true, Defer: true}},
+		{"http://example.com/a.png", nil, "no-cors", "omit", OpaqueCache, &PrecacheRequest{CrossOrigin: true, Mode: "no-cors", Credentials: "omit", Opaque: true}},
+	}
+
+	for _, test := range tests {
+
+		opts := DefaultServiceWorkerOptions()
+		opts.CORSOrigins = test.cors
+		opts.CrossOriginMode = test.mode
+		opts.CrossOriginCredentials = test.credentials
+		opts.OpaquePolicy = test.policy
+
+		requests := PrecacheRequests([]string{test.uri}, self, opts)
+
+		if test.expected == nil {
+
+			if len(requests) != 0 {
+				t.Fatalf("Expected %s to be skipped, got %+v", test.uri, requests[0])
+			}
+
+			continue
+		}
+
+		if len(requests) != 1 {
+			t.Fatalf("Expected one request for %s, got %d", test.uri, len(requests))
+		}
+
+		test.expected.URL = test.uri
+
+		if *requests[0] != *test.expected {
+			t.Fatalf("Expected request for %s to be %+v, got %+v", test.uri, test.expected, requests[0])
+		}
+	}
+}
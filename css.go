@@ -0,0 +1,199 @@
+package offline
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var re_css_comment *regexp.Regexp
+var re_css_url *regexp.Regexp
+var re_css_import *regexp.Regexp
+var re_css_imageset *regexp.Regexp
+var re_css_string *regexp.Regexp
+
+func init() {
+
+	re_css_comment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	re_css_url = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)`)
+	re_css_import = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+	re_css_imageset = regexp.MustCompile(`(?i)(?:-webkit-)?image-set\(`)
+	re_css_string = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+}
+
+type CSSReference struct {
+	URI    string
+	Import bool
+	offset int
+}
+
+// CSSReferences returns the url(), @import and image-set() references in a
+// block of CSS in the order they appear. References to fragments (for example
+// SVG filters) and data: URIs are not included.
+func CSSReferences(css string) []*CSSReference {
+
+	// replace comments with whitespace of the same length so that offsets
+	// still line up with the original text
+
+	css = re_css_comment.ReplaceAllStringFunc(css, func(c string) string {
+		return strings.Repeat(" ", len(c))
+	})
+
+	refs := make([]*CSSReference, 0)
+
+	for _, m := range re_css_url.FindAllStringSubmatchIndex(css, -1) {
+
+		uri := firstSubmatch(css, m)
+
+		before := strings.TrimRightFunc(css[:m[0]], unicode.IsSpace)
+		is_import := strings.HasSuffix(strings.ToLower(before), "@import")
+
+		refs = append(refs, &CSSReference{URI: uri, Import: is_import, offset: m[0]})
+	}
+
+	for _, m := range re_css_import.FindAllStringSubmatchIndex(css, -1) {
+
+		uri := firstSubmatch(css, m)
+		refs = append(refs, &CSSReference{URI: uri, Import: true, offset: m[0]})
+	}
+
+	for _, m := range re_css_imageset.FindAllStringIndex(css, -1) {
+
+		start := m[1]
+		end := matchingParen(css, start)
+
+		inner := re_css_url.ReplaceAllStringFunc(css[start:end], func(u string) string {
+			return strings.Repeat(" ", len(u))
+		})
+
+		for _, sm := range re_css_string.FindAllStringSubmatchIndex(inner, -1) {
+
+			uri := firstSubmatch(inner, sm)
+			refs = append(refs, &CSSReference{URI: uri, offset: start + sm[0]})
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].offset < refs[j].offset
+	})
+
+	filtered := make([]*CSSReference, 0)
+
+	for _, r := range refs {
+
+		r.URI = strings.TrimSpace(r.URI)
+
+		if r.URI == "" || strings.HasPrefix(r.URI, "#") || strings.HasPrefix(strings.ToLower(r.URI), "data:") {
+			continue
+		}
+
+		filtered = append(filtered, r)
+	}
+
+	return filtered
+}
+
+func firstSubmatch(str string, m []int) string {
+
+	for i := 2; i+1 < len(m); i += 2 {
+
+		if m[i] != -1 {
+			return str[m[i]:m[i+1]]
+		}
+	}
+
+	return ""
+}
+
+func matchingParen(str string, start int) int {
+
+	depth := 1
+
+	for idx := start; idx < len(str); idx++ {
+
+		switch str[idx] {
+		case '(':
+			depth += 1
+		case ')':
+			depth -= 1
+
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+
+	return len(str)
+}
+
+// followStylesheet fetches the stylesheet href, relative to base, and returns
+// every resource it references resolved against the stylesheet's own location.
+// @import-ed stylesheets are followed until opts.StylesheetDepth is reached.
+func followStylesheet(base *url.URL, href string, resolver *Resolver, opts *ServiceWorkerOptions, depth int, seen map[string]bool) []*Asset {
+
+	to_cache := make([]*Asset, 0)
+
+	if depth > opts.StylesheetDepth {
+		return to_cache
+	}
+
+	sheet_url, body, ok := fetchReference(base, href, resolver, seen)
+
+	if !ok {
+		return to_cache
+	}
+
+	return styleReferences(string(body), sheet_url, resolver, opts, depth, seen)
+}
+
+// styleReferences returns the resources referenced by a block of CSS resolved
+// against base. base is the stylesheet's own URL for external stylesheets and
+// the document's base URL for inline styles; it may be nil.
+func styleReferences(css string, base *url.URL, resolver *Resolver, opts *ServiceWorkerOptions, depth int, seen map[string]bool) []*Asset {
+
+	to_cache := make([]*Asset, 0)
+
+	source := ""
+
+	if base != nil {
+		source = base.String()
+	}
+
+	for _, ref := range CSSReferences(css) {
+
+		uri, ok := resolver.ResolveReference(base, ref.URI)
+
+		if !ok {
+			continue
+		}
+
+		// url() references without a recognizable file extension are most
+		// likely images
+
+		kind := extensionKind(uri)
+
+		if ref.Import {
+			kind = KindStyle
+		} else if kind == "" {
+			kind = KindImage
+		}
+
+		a := &Asset{
+			URL:    uri,
+			Value:  ref.URI,
+			Kind:   kind,
+			Source: source,
+			Line:   strings.Count(css[:ref.offset], "\n") + 1,
+		}
+
+		to_cache = append(to_cache, a)
+
+		if ref.Import && opts.FollowStylesheets {
+			to_cache = append(to_cache, followStylesheet(base, ref.URI, resolver, opts, depth+1, seen)...)
+		}
+	}
+
+	return to_cache
+}
@@ -0,0 +1,41 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestCSSReferences(t *testing.T) {
+
+	tests := []struct {
+		css      string
+		expected []CSSReference
+	}{
+		{"", []CSSReference{}},
+		{"body { background: url(a.png) }", []CSSReference{{URI: "a.png"}}},
+		{`a { background: url("b.png") } b { background: URL( 'c.png' ) }`, []CSSReference{{URI: "b.png"}, {URI: "c.png"}}},
+		{`@import "d.css"; @import url(e.css) print;`, []CSSReference{{URI: "d.css", Import: true}, {URI: "e.css", Import: true}}},
+		{`@import 'f.css';`, []CSSReference{{URI: "f.css", Import: true}}},
+		{"/* url(g.png) */ h { background: url(h.png) }", []CSSReference{{URI: "h.png"}}},
+		{"i { filter: url(#blur); background: url(data:image/png;base64,AAAA) }", []CSSReference{}},
+		{`j { background: image-set("j1.png" 1x, url(j2.png) 2x) }`, []CSSReference{{URI: "j1.png"}, {URI: "j2.png"}}},
+		{`k { background: -webkit-image-set('k1.png' 1x, 'k2.png' 2x) }`, []CSSReference{{URI: "k1.png"}, {URI: "k2.png"}}},
+		{"@font-face { src: url(l.woff2) format('woff2'), url(l.woff) format('woff') }", []CSSReference{{URI: "l.woff2"}, {URI: "l.woff"}}},
+		{"m { background: url(  ) }", []CSSReference{}},
+	}
+
+	for _, test := range tests {
+
+		refs := CSSReferences(test.css)
+
+		if len(refs) != len(test.expected) {
+			t.Fatalf("Expected %d references for '%s', got %d", len(test.expected), test.css, len(refs))
+		}
+
+		for idx, r := range refs {
+
+			if r.URI != test.expected[idx].URI || r.Import != test.expected[idx].Import {
+				t.Fatalf("Expected reference %d for '%s' to be %v, got %v", idx, test.css, test.expected[idx], *r)
+			}
+		}
+	}
+}
@@ -0,0 +1,623 @@
+package offline
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+const (
+	ExtractURL    = "url"
+	ExtractSrcset = "srcset"
+	ExtractCSS    = "css"
+)
+
+const (
+	FollowStylesheet = "stylesheet"
+	FollowManifest   = "manifest"
+	FollowModule     = "module"
+	FollowDocument   = "document"
+)
+
+// Reference is a URI found in a document, before it has been resolved.
+type Reference struct {
+	URI       string
+	Element   string
+	Attribute string
+	// Follow indicates that the resource the URI points to should itself be
+	// read for more references. Valid options are: stylesheet, manifest, module,
+	// document.
+	Follow string
+	// Kind is the kind of asset the URI points to (see InferKind). It may be
+	// empty in which case it is inferred from the element, attribute and URI.
+	Kind string
+}
+
+// Extractor is the interface for things that derive references from an HTML
+// element. Extract is called once for every element node in a document.
+type Extractor interface {
+	Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error)
+}
+
+// ExtractorRule is a declarative Extractor that reads a single attribute from
+// matching elements, for example:
+//
+//	element=model-viewer attr=src
+//	element=div attr=data-bg
+//	element=img attr=data-srcset type=srcset
+//	element=* attr=data-style type=css
+//
+// Valid types are: url (the default), srcset and css. An optional kind key
+// (for example kind=image) sets the kind of asset that references point to.
+type ExtractorRule struct {
+	Element   string
+	Attribute string
+	Type      string
+	Kind      string
+}
+
+func NewExtractorRule(element string, attr string, rule_type string) (*ExtractorRule, error) {
+
+	if element == "" || attr == "" {
+		return nil, fmt.Errorf("Invalid extractor rule, missing element or attribute")
+	}
+
+	switch rule_type {
+	case "":
+		rule_type = ExtractURL
+	case ExtractURL, ExtractSrcset, ExtractCSS:
+		// pass
+	default:
+		return nil, fmt.Errorf("Invalid extractor rule type '%s'", rule_type)
+	}
+
+	r := &ExtractorRule{
+		Element:   strings.ToLower(element),
+		Attribute: strings.ToLower(attr),
+		Type:      rule_type,
+	}
+
+	return r, nil
+}
+
+// ParseExtractorRule parses a string of space-separated key=value pairs in to
+// an ExtractorRule. Valid keys are: element, attr, type and kind.
+func ParseExtractorRule(str string) (*ExtractorRule, error) {
+
+	var element string
+	var attr string
+	var rule_type string
+	var kind string
+
+	for _, pair := range strings.Fields(str) {
+
+		kv := strings.SplitN(pair, "=", 2)
+
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid extractor rule '%s'", str)
+		}
+
+		switch kv[0] {
+		case "element":
+			element = kv[1]
+		case "attr":
+			attr = kv[1]
+		case "type":
+			rule_type = kv[1]
+		case "kind":
+			kind = kv[1]
+		default:
+			return nil, fmt.Errorf("Invalid extractor rule key '%s'", kv[0])
+		}
+	}
+
+	r, err := NewExtractorRule(element, attr, rule_type)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != "" {
+
+		if !IsValidKind(kind) {
+			return nil, fmt.Errorf("Invalid extractor rule kind '%s'", kind)
+		}
+
+		r.Kind = kind
+	}
+
+	return r, nil
+}
+
+func (r *ExtractorRule) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if r.Element != "*" && r.Element != n.Data {
+		return refs, nil
+	}
+
+	value, ok := attrValue(n, r.Attribute)
+
+	if !ok {
+		return refs, nil
+	}
+
+	switch r.Type {
+	case ExtractSrcset:
+
+		for _, uri := range SelectSrcset(value, attrs2map(n.Attr...)["sizes"], opts) {
+			refs = append(refs, &Reference{URI: uri, Element: n.Data, Attribute: r.Attribute, Kind: r.Kind})
+		}
+
+	case ExtractCSS:
+
+		refs = cssReferences(value, n.Data, r.Attribute)
+
+	default:
+		refs = append(refs, &Reference{URI: value, Element: n.Data, Attribute: r.Attribute, Kind: r.Kind})
+	}
+
+	return refs, nil
+}
+
+func (r *ExtractorRule) String() string {
+
+	str := fmt.Sprintf("element=%s attr=%s type=%s", r.Element, r.Attribute, r.Type)
+
+	if r.Kind != "" {
+		str = fmt.Sprintf("%s kind=%s", str, r.Kind)
+	}
+
+	return str
+}
+
+// ImageExtractor derives references from <img src="..." srcset="..."> elements
+// applying opts.SrcsetPolicy to the combined set of candidates.
+type ImageExtractor struct{}
+
+func (e *ImageExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if n.Data != "img" {
+		return refs, nil
+	}
+
+	img := attrs2map(n.Attr...)
+
+	src, src_ok := img["src"]
+	srcset, srcset_ok := img["srcset"]
+
+	if !srcset_ok {
+
+		if src_ok {
+			refs = append(refs, &Reference{URI: src, Element: n.Data, Attribute: "src"})
+		}
+
+		return refs, nil
+	}
+
+	candidates := ParseSrcset(srcset)
+	add_src := false
+
+	if src_ok && src != "" {
+
+		// the src attribute is only a candidate if srcset doesn't already
+		// define a 1x or width-described image, but when we are caching
+		// everything we want it regardless
+
+		add_src = opts.SrcsetPolicy == "" || opts.SrcsetPolicy == SrcsetAll
+
+		if !add_src {
+
+			add_src = true
+
+			for _, c := range candidates {
+
+				if c.Width != 0 || c.Density == 1.0 || (c.Width == 0 && c.Density == 0) {
+					add_src = false
+					break
+				}
+			}
+		}
+
+		if add_src {
+			candidates = append([]*SrcsetCandidate{&SrcsetCandidate{URL: src, Density: 1.0}}, candidates...)
+		}
+	}
+
+	for idx, uri := range selectSrcsetCandidates(candidates, img["sizes"], opts) {
+
+		attr := "srcset"
+
+		if add_src && idx == 0 && uri == src {
+			attr = "src"
+		}
+
+		refs = append(refs, &Reference{URI: uri, Element: n.Data, Attribute: attr})
+	}
+
+	return refs, nil
+}
+
+// LinkExtractor derives references from <link rel="..." href="..."> elements
+// whose rel attribute contains one of the tokens in opts.LinkRels. Preload and
+// prefetch links are only included if their as attribute is listed in
+// opts.PreloadAs.
+type LinkExtractor struct{}
+
+func (e *LinkExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if n.Data != "link" {
+		return refs, nil
+	}
+
+	link := attrs2map(n.Attr...)
+
+	href, href_ok := link["href"]
+
+	if !href_ok {
+		return refs, nil
+	}
+
+	rels := make(map[string]bool)
+
+	for _, r := range strings.Fields(strings.ToLower(link["rel"])) {
+		rels[r] = true
+	}
+
+	as := strings.ToLower(strings.TrimSpace(link["as"]))
+
+	include := false
+	follow := ""
+	kind := ""
+
+	for _, r := range opts.LinkRels {
+
+		if !rels[strings.ToLower(r)] {
+			continue
+		}
+
+		switch strings.ToLower(r) {
+		case "stylesheet":
+
+			if opts.SkipAlternateStylesheets && rels["alternate"] {
+				continue
+			}
+
+			if opts.SkipPrintStylesheets && isPrintMedia(link["media"]) {
+				continue
+			}
+
+			follow = FollowStylesheet
+			kind = KindStyle
+
+		case "preload", "prefetch":
+
+			if !hasString(opts.PreloadAs, as) {
+				continue
+			}
+
+			if as == "style" {
+				follow = FollowStylesheet
+			}
+
+			kind = preloadKind(as)
+
+		case "manifest":
+
+			follow = FollowManifest
+			kind = KindOther
+
+		case "modulepreload":
+
+			follow = FollowModule
+			kind = KindScript
+
+		case "icon", "apple-touch-icon", "apple-touch-startup-image", "mask-icon":
+
+			kind = KindImage
+		}
+
+		include = true
+	}
+
+	if include {
+		refs = append(refs, &Reference{URI: href, Element: n.Data, Attribute: "href", Follow: follow, Kind: kind})
+	}
+
+	return refs, nil
+}
+
+// ScriptExtractor derives references from <script src="..."> elements with an
+// executable type. For module scripts, both external and inline, the modules
+// they import are followed as well.
+type ScriptExtractor struct{}
+
+func (e *ScriptExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if n.Data != "script" {
+		return refs, nil
+	}
+
+	script := attrs2map(n.Attr...)
+
+	script_type := script["type"]
+
+	if !IsJavaScriptType(script_type) {
+		return refs, nil
+	}
+
+	follow := ""
+
+	if IsModuleType(script_type) {
+		follow = FollowModule
+	}
+
+	src, src_ok := script["src"]
+
+	if src_ok {
+		refs = append(refs, &Reference{URI: src, Element: n.Data, Attribute: "src", Follow: follow})
+		return refs, nil
+	}
+
+	if follow != FollowModule {
+		return refs, nil
+	}
+
+	var body strings.Builder
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+		if c.Type == html.TextNode {
+			body.WriteString(c.Data)
+		}
+	}
+
+	for _, spec := range ModuleSpecifiers(body.String()) {
+		refs = append(refs, &Reference{URI: spec, Element: n.Data, Attribute: "", Follow: FollowModule})
+	}
+
+	return refs, nil
+}
+
+// StyleExtractor derives references from the contents of <style> elements and
+// from style="..." attributes on any element.
+type StyleExtractor struct{}
+
+func (e *StyleExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	style, style_ok := attrs2map(n.Attr...)["style"]
+
+	if style_ok {
+		refs = append(refs, cssReferences(style, n.Data, "style")...)
+	}
+
+	if n.Data == "style" {
+
+		var css strings.Builder
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+			if c.Type == html.TextNode {
+				css.WriteString(c.Data)
+			}
+		}
+
+		refs = append(refs, cssReferences(css.String(), n.Data, "")...)
+	}
+
+	return refs, nil
+}
+
+// LazyLoadExtractor derives references from the data-src, data-srcset (and
+// similar) attributes used by common lazy-loading libraries on any element.
+// It does nothing unless opts.LazyLoad is true.
+type LazyLoadExtractor struct{}
+
+func (e *LazyLoadExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if !opts.LazyLoad {
+		return refs, nil
+	}
+
+	rules := []*ExtractorRule{
+		&ExtractorRule{Element: "*", Attribute: "data-src", Type: ExtractURL},
+		&ExtractorRule{Element: "*", Attribute: "data-srcset", Type: ExtractSrcset},
+		&ExtractorRule{Element: "*", Attribute: "data-lazy-src", Type: ExtractURL},
+		&ExtractorRule{Element: "*", Attribute: "data-lazy-srcset", Type: ExtractSrcset},
+		&ExtractorRule{Element: "*", Attribute: "data-original", Type: ExtractURL},
+		&ExtractorRule{Element: "*", Attribute: "data-bg", Type: ExtractURL},
+		&ExtractorRule{Element: "*", Attribute: "data-poster", Type: ExtractURL, Kind: KindImage},
+	}
+
+	for _, r := range rules {
+
+		rule_refs, err := r.Extract(n, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, rule_refs...)
+	}
+
+	return refs, nil
+}
+
+// InputExtractor derives references from <input type="image" src="...">
+// elements.
+type InputExtractor struct{}
+
+func (e *InputExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if n.Data != "input" {
+		return refs, nil
+	}
+
+	input := attrs2map(n.Attr...)
+
+	src, src_ok := input["src"]
+
+	if src_ok && strings.EqualFold(strings.TrimSpace(input["type"]), "image") {
+		refs = append(refs, &Reference{URI: src, Element: n.Data, Attribute: "src"})
+	}
+
+	return refs, nil
+}
+
+// FrameExtractor derives references from <iframe src="..."> (and <frame>)
+// elements. It does nothing unless opts.FollowFrames is true. Cross-origin
+// frames are ignored.
+type FrameExtractor struct{}
+
+func (e *FrameExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if !opts.FollowFrames || (n.Data != "iframe" && n.Data != "frame") {
+		return refs, nil
+	}
+
+	src, src_ok := attrs2map(n.Attr...)["src"]
+
+	if src_ok {
+		refs = append(refs, &Reference{URI: src, Element: n.Data, Attribute: "src", Follow: FollowDocument})
+	}
+
+	return refs, nil
+}
+
+// DefaultExtractors returns the list of extractors used to derive references
+// from the elements described in the README.
+func DefaultExtractors() []Extractor {
+
+	// <picture> uses <source srcset="...">
+	// <video> uses <source src="...">
+	// SVG <image> and <use> elements store xlink:href attributes as "href"
+	// with an "xlink" namespace
+
+	extractors := []Extractor{
+		&StyleExtractor{},
+		&ImageExtractor{},
+		&LinkExtractor{},
+		&ScriptExtractor{},
+		&ExtractorRule{Element: "source", Attribute: "srcset", Type: ExtractSrcset},
+		&ExtractorRule{Element: "source", Attribute: "src", Type: ExtractURL},
+		&ExtractorRule{Element: "video", Attribute: "src", Type: ExtractURL},
+		&ExtractorRule{Element: "video", Attribute: "poster", Type: ExtractURL, Kind: KindImage},
+		&ExtractorRule{Element: "audio", Attribute: "src", Type: ExtractURL},
+		&ExtractorRule{Element: "track", Attribute: "src", Type: ExtractURL},
+		&ExtractorRule{Element: "object", Attribute: "data", Type: ExtractURL},
+		&ExtractorRule{Element: "embed", Attribute: "src", Type: ExtractURL},
+		&ExtractorRule{Element: "image", Attribute: "href", Type: ExtractURL},
+		&ExtractorRule{Element: "use", Attribute: "href", Type: ExtractURL},
+		&InputExtractor{},
+		&FrameExtractor{},
+		&LazyLoadExtractor{},
+		&MetadataExtractor{},
+	}
+
+	return extractors
+}
+
+func cssReferences(css string, element string, attr string) []*Reference {
+
+	refs := make([]*Reference, 0)
+
+	for _, r := range CSSReferences(css) {
+
+		ref := &Reference{
+			URI:       r.URI,
+			Element:   element,
+			Attribute: attr,
+		}
+
+		if r.Import {
+			ref.Follow = FollowStylesheet
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// preloadKind returns the kind of asset for a <link rel="preload"> element's
+// as attribute.
+func preloadKind(as string) string {
+
+	switch as {
+	case "image":
+		return KindImage
+	case "style":
+		return KindStyle
+	case "script", "worker":
+		return KindScript
+	case "font":
+		return KindFont
+	case "audio", "video", "track":
+		return KindMedia
+	case "document":
+		return KindDocument
+	default:
+		return ""
+	}
+}
+
+func isPrintMedia(media string) bool {
+
+	media = strings.TrimSpace(strings.ToLower(media))
+
+	if media == "" {
+		return false
+	}
+
+	for _, q := range strings.Split(media, ",") {
+
+		q = strings.TrimSpace(q)
+
+		if q != "print" && !strings.HasPrefix(q, "print ") && !strings.HasPrefix(q, "only print") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasString(candidates []string, str string) bool {
+
+	for _, c := range candidates {
+
+		if strings.EqualFold(c, str) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attrValue returns the value of the attribute name on n. name may be prefixed
+// with a namespace, for example "xlink:href".
+func attrValue(n *html.Node, name string) (string, bool) {
+
+	for _, a := range n.Attr {
+
+		if a.Key == name || (a.Namespace != "" && a.Namespace+":"+a.Key == name) {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
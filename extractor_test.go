@@ -0,0 +1,48 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestParseExtractorRule(t *testing.T) {
+
+	tests := []struct {
+		str      string
+		expected *ExtractorRule
+	}{
+		{"element=model-viewer attr=src", &ExtractorRule{Element: "model-viewer", Attribute: "src", Type: ExtractURL}},
+		{"element=DIV attr=Data-BG", &ExtractorRule{Element: "div", Attribute: "data-bg", Type: ExtractURL}},
+		{"element=img attr=data-srcset type=srcset", &ExtractorRule{Element: "img", Attribute: "data-srcset", Type: ExtractSrcset}},
+		{"  element=*   attr=data-style type=css ", &ExtractorRule{Element: "*", Attribute: "data-style", Type: ExtractCSS}},
+		{"element=div attr=data-poster type=url kind=image", &ExtractorRule{Element: "div", Attribute: "data-poster", Type: ExtractURL, Kind: KindImage}},
+		{"", nil},
+		{"element=div", nil},
+		{"attr=src", nil},
+		{"element=div attr", nil},
+		{"element=div attr=src type=json", nil},
+		{"element=div attr=src kind=video", nil},
+		{"element=div attr=src colour=red", nil},
+	}
+
+	for _, test := range tests {
+
+		r, err := ParseExtractorRule(test.str)
+
+		if test.expected == nil {
+
+			if err == nil {
+				t.Fatalf("Expected '%s' to fail, got %+v", test.str, r)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", test.str, err)
+		}
+
+		if *r != *test.expected {
+			t.Fatalf("Expected '%s' to parse as %+v, got %+v", test.str, test.expected, r)
+		}
+	}
+}
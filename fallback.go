@@ -0,0 +1,54 @@
+package offline
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// resolveFallback resolves the fallback uri for the document that resolver
+// belongs to. uri may also be the absolute path to a local file in which case
+// it is written relative to the service worker's scope, provided the document
+// is a local file too.
+func resolveFallback(resolver *Resolver, uri string) (string, error) {
+
+	if filepath.IsAbs(uri) && resolver.location != nil && resolver.location.Scheme == "file" {
+
+		u, err := fileURL(uri)
+
+		if err != nil {
+			return "", err
+		}
+
+		return resolver.Format(u), nil
+	}
+
+	resolved, ok := resolver.Resolve(uri)
+
+	if !ok {
+		return "", fmt.Errorf("Invalid fallback '%s'", uri)
+	}
+
+	return resolved, nil
+}
+
+// fallbackKind returns the kind of asset for a fallback keyed by the request
+// destination dest.
+func fallbackKind(dest string, uri string) string {
+
+	switch dest {
+	case "document", "iframe", "frame":
+		return KindDocument
+	case "image":
+		return KindImage
+	case "font":
+		return KindFont
+	case "style":
+		return KindStyle
+	case "script", "worker", "sharedworker":
+		return KindScript
+	case "audio", "video", "track":
+		return KindMedia
+	default:
+		return InferKind("", "", uri)
+	}
+}
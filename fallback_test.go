@@ -0,0 +1,56 @@
+package offline
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestResolveFallback(t *testing.T) {
+
+	tests := []struct {
+		location string
+		uri      string
+		expected string
+		ok       bool
+	}{
+		{"file:///site/index.html", "/site/offline.html", "./offline.html", true},
+		{"file:///site/a/index.html", "/site/offline.html", "../offline.html", true},
+		{"file:///site/a/b/index.html", "/site/offline.html", "../../offline.html", true},
+		{"file:///site/a/index.html", "/site/a/img/offline.png", "./img/offline.png", true},
+		{"file:///site/a/index.html", "/elsewhere/offline.html", "../../elsewhere/offline.html", true},
+		{"file:///site/a/index.html", "offline.html", "./offline.html", true},
+		{"https://example.com/a/index.html", "/offline.html", "/offline.html", true},
+		{"https://example.com/a/index.html", "../offline.html", "/offline.html", true},
+		{"https://example.com/a/index.html", "offline.html", "./offline.html", true},
+		{"https://example.com/a/index.html", "data:text/html,offline", "", false},
+	}
+
+	for _, test := range tests {
+
+		location, err := url.Parse(test.location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolver, err := NewResolver(location, DefaultServiceWorkerOptions())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolved, err := resolveFallback(resolver, test.uri)
+
+		if test.ok && err != nil {
+			t.Fatalf("Failed to resolve fallback %s for %s, %v", test.uri, test.location, err)
+		}
+
+		if !test.ok && err == nil {
+			t.Fatalf("Expected fallback %s for %s to fail, got '%s'", test.uri, test.location, resolved)
+		}
+
+		if resolved != test.expected {
+			t.Fatalf("Expected fallback %s for %s to resolve to '%s', got '%s'", test.uri, test.location, test.expected, resolved)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package offline
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fetch_client is used to fetch remote documents and the resources they link
+// to. A hung server fails the request rather than blocking it indefinitely.
+var fetch_client = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// max_fetch_size is the maximum number of bytes read from a linked resource.
+const max_fetch_size = 10 * 1024 * 1024
+
+func fileURL(path string) (*url.URL, error) {
+
+	abs_path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u := &url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(abs_path),
+	}
+
+	return u, nil
+}
+
+func fetch(u *url.URL) (io.ReadCloser, error) {
+
+	switch strings.ToLower(u.Scheme) {
+
+	case "file":
+
+		return os.Open(filepath.FromSlash(u.Path))
+
+	case "http", "https":
+
+		rsp, err := fetch_client.Get(u.String())
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			return nil, fmt.Errorf("Failed to fetch %s: %s", u.String(), rsp.Status)
+		}
+
+		return rsp.Body, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported scheme '%s'", u.Scheme)
+	}
+}
+
+// fetchReference resolves href against base and returns the resolved URL and
+// the body of the resource it points to. It returns false if href can not be
+// resolved to an absolute URL, has already been fetched (according to seen),
+// is larger than max_fetch_size or can not be read. Local files are only read
+// if the document resolver is for is itself a local file, and root-relative
+// or protocol-relative references are never read from the filesystem.
+func fetchReference(base *url.URL, href string, resolver *Resolver, seen map[string]bool) (*url.URL, []byte, bool) {
+
+	u, err := url.Parse(strings.TrimSpace(href))
+
+	if err != nil {
+		return nil, nil, false
+	}
+
+	if base != nil {
+
+		// root-relative and protocol-relative references in local files
+		// can't be mapped back on to the filesystem (see Resolver.Resolve)
+		// so they aren't fetched
+
+		if strings.EqualFold(base.Scheme, "file") && u.Scheme == "" && (u.Host != "" || strings.HasPrefix(u.Path, "/")) {
+			return nil, nil, false
+		}
+
+		u = base.ResolveReference(u)
+	}
+
+	if !u.IsAbs() {
+		return nil, nil, false
+	}
+
+	if strings.EqualFold(u.Scheme, "file") && !resolver.IsLocal() {
+		return nil, nil, false
+	}
+
+	u.Fragment = ""
+	key := u.String()
+
+	if seen[key] {
+		return nil, nil, false
+	}
+
+	seen[key] = true
+
+	fh, err := fetch(u)
+
+	if err != nil {
+		return nil, nil, false
+	}
+
+	defer fh.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(fh, max_fetch_size+1))
+
+	if err != nil || len(body) > max_fetch_size {
+		return nil, nil, false
+	}
+
+	return u, body, true
+}
@@ -0,0 +1,131 @@
+package offline
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchReferenceFileScheme(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "offline")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "style.css")
+
+	err = ioutil.WriteFile(path, []byte("a { background: url(a.png) }"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file_url, err := fileURL(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultServiceWorkerOptions()
+
+	tests := []struct {
+		location string
+		expected bool
+	}{
+		{"https://example.com/index.html", false},
+		{"http://example.com/index.html", false},
+		{"file://" + filepath.ToSlash(filepath.Join(dir, "index.html")), true},
+	}
+
+	for _, test := range tests {
+
+		location, err := url.Parse(test.location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolver, err := NewResolver(location, opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, ok := fetchReference(location, file_url.String(), resolver, make(map[string]bool))
+
+		if ok != test.expected {
+			t.Fatalf("Expected fetching %s from %s to return %t", file_url.String(), test.location, test.expected)
+		}
+	}
+}
+
+func TestFetchReferenceRootRelative(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "offline")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	page := filepath.Join(dir, "site", "page")
+
+	err = os.MkdirAll(page, 0755)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(dir, "root.css"),
+		filepath.Join(page, "style.css"),
+	} {
+
+		err = ioutil.WriteFile(path, []byte("a { background: url(a.png) }"), 0644)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	location, err := fileURL(filepath.Join(page, "index.html"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := NewResolver(location, DefaultServiceWorkerOptions())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root_css := filepath.ToSlash(filepath.Join(dir, "root.css"))
+
+	tests := []struct {
+		href     string
+		expected bool
+	}{
+		{"style.css", true},
+		{"./style.css", true},
+		{root_css, false},
+		{"//cdn.example.com" + root_css, false},
+		{"//cdn.example.com", false},
+	}
+
+	for _, test := range tests {
+
+		_, _, ok := fetchReference(location, test.href, resolver, make(map[string]bool))
+
+		if ok != test.expected {
+			t.Fatalf("Expected fetching %s from %s to return %t", test.href, location.String(), test.expected)
+		}
+	}
+}
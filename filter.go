@@ -0,0 +1,188 @@
+package offline
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CacheFilter defines the rules used to decide which URIs are included in a
+// cache list. If any of Include, IncludeRegexps or IncludeExtensions are set a
+// URI must match at least one of them. A URI matching any of Exclude,
+// ExcludeRegexps, ExcludeExtensions or DenyOrigins is always excluded. If
+// AllowOrigins is set cross-origin URIs must have one of the origins listed.
+// Glob patterns are matched against the URI as it appears in the cache list;
+// "*" matches any sequence of characters and "?" matches any single character.
+type CacheFilter struct {
+	Include           []string
+	Exclude           []string
+	IncludeRegexps    []*regexp.Regexp
+	ExcludeRegexps    []*regexp.Regexp
+	AllowOrigins      []string
+	DenyOrigins       []string
+	IncludeExtensions []string
+	ExcludeExtensions []string
+	MaxEntries        int
+}
+
+func NewCacheFilter() *CacheFilter {
+
+	f := CacheFilter{
+		Include:           []string{},
+		Exclude:           []string{},
+		IncludeRegexps:    []*regexp.Regexp{},
+		ExcludeRegexps:    []*regexp.Regexp{},
+		AllowOrigins:      []string{},
+		DenyOrigins:       []string{},
+		IncludeExtensions: []string{},
+		ExcludeExtensions: []string{},
+		MaxEntries:        0,
+	}
+
+	return &f
+}
+
+// Apply returns the URIs in uris which satisfy the filter's rules, truncated
+// to f.MaxEntries if it is greater than zero. self is the origin (scheme and
+// host) that relative URIs belong to; it may be empty.
+func (f *CacheFilter) Apply(uris []string, self string) []string {
+
+	filtered := make([]string, 0)
+
+	for _, uri := range uris {
+
+		if f.MaxEntries > 0 && len(filtered) >= f.MaxEntries {
+			break
+		}
+
+		if f.Allow(uri, self) {
+			filtered = append(filtered, uri)
+		}
+	}
+
+	return filtered
+}
+
+// Allow returns true if uri satisfies the filter's rules. self is the origin
+// (scheme and host) that relative URIs belong to; it may be empty.
+func (f *CacheFilter) Allow(uri string, self string) bool {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return false
+	}
+
+	origin := uriOrigin(uri, self)
+
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+
+	for _, pattern := range f.Exclude {
+
+		if matchGlob(pattern, uri) {
+			return false
+		}
+	}
+
+	for _, re := range f.ExcludeRegexps {
+
+		if re.MatchString(uri) {
+			return false
+		}
+	}
+
+	if ext != "" && hasExtension(f.ExcludeExtensions, ext) {
+		return false
+	}
+
+	if origin != "" && hasOrigin(f.DenyOrigins, origin) {
+		return false
+	}
+
+	if len(f.AllowOrigins) > 0 && !strings.EqualFold(origin, self) && !hasOrigin(f.AllowOrigins, origin) {
+		return false
+	}
+
+	if len(f.Include) == 0 && len(f.IncludeRegexps) == 0 && len(f.IncludeExtensions) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.Include {
+
+		if matchGlob(pattern, uri) {
+			return true
+		}
+	}
+
+	for _, re := range f.IncludeRegexps {
+
+		if re.MatchString(uri) {
+			return true
+		}
+	}
+
+	if ext != "" && hasExtension(f.IncludeExtensions, ext) {
+		return true
+	}
+
+	return false
+}
+
+func matchGlob(pattern string, str string) bool {
+
+	re, err := regexp.Compile(globRegexp(pattern))
+
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(str)
+}
+
+// globRegexp returns the regular expression for the glob pattern.
+func globRegexp(pattern string) string {
+
+	var buf strings.Builder
+	buf.WriteString("^")
+
+	for _, r := range pattern {
+
+		switch r {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	buf.WriteString("$")
+
+	return buf.String()
+}
+
+func hasExtension(extensions []string, ext string) bool {
+
+	for _, e := range extensions {
+
+		if strings.EqualFold(strings.TrimPrefix(e, "."), ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasOrigin(origins []string, origin string) bool {
+
+	for _, o := range origins {
+
+		if strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package offline
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGlobRegexp(t *testing.T) {
+
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"*.png", `^.*\.png$`},
+		{"./img/?.jpg", `^\./img/.\.jpg$`},
+		{"https://cdn.example.com/*", `^https://cdn\.example\.com/.*$`},
+		{"./a+b(c)[d]{e}|f^$.css", `^\./a\+b\(c\)\[d\]\{e\}\|f\^\$\.css$`},
+		{"", `^$`},
+	}
+
+	for _, test := range tests {
+
+		re := globRegexp(test.pattern)
+
+		if re != test.expected {
+			t.Fatalf("Expected glob '%s' to be '%s', got '%s'", test.pattern, test.expected, re)
+		}
+	}
+}
+
+func TestCacheFilterAllow(t *testing.T) {
+
+	self := "https://example.com"
+
+	tests := []struct {
+		filter   *CacheFilter
+		uri      string
+		expected bool
+	}{
+		{&CacheFilter{}, "./a.png", true},
+		{&CacheFilter{Exclude: []string{"*.png"}}, "./a.png", false},
+		{&CacheFilter{Exclude: []string{"*.png"}}, "./a.jpg", true},
+		{&CacheFilter{Exclude: []string{"./a?.png"}}, "./ab.png", false},
+		{&CacheFilter{Exclude: []string{"./a?.png"}}, "./abc.png", true},
+		{&CacheFilter{Exclude: []string{"./a+b.png"}}, "./aab.png", true},
+		{&CacheFilter{Exclude: []string{"./a+b.png"}}, "./a+b.png", false},
+		{&CacheFilter{Include: []string{"./img/*"}}, "./img/a.png", true},
+		{&CacheFilter{Include: []string{"./img/*"}}, "./css/a.css", false},
+		{&CacheFilter{Include: []string{"./img/*"}, Exclude: []string{"*.gif"}}, "./img/a.gif", false},
+		{&CacheFilter{IncludeRegexps: []*regexp.Regexp{regexp.MustCompile(`\.(png|jpg)$`)}}, "./a.jpg", true},
+		{&CacheFilter{IncludeRegexps: []*regexp.Regexp{regexp.MustCompile(`\.(png|jpg)$`)}}, "./a.css", false},
+		{&CacheFilter{ExcludeRegexps: []*regexp.Regexp{regexp.MustCompile(`^https?://`)}}, "https://cdn.example.com/a.js", false},
+		{&CacheFilter{IncludeExtensions: []string{"css", ".JS"}}, "./a.js?v=1", true},
+		{&CacheFilter{IncludeExtensions: []string{"css"}}, "./a.png", false},
+		{&CacheFilter{IncludeExtensions: []string{"css"}}, "./", false},
+		{&CacheFilter{ExcludeExtensions: []string{"mp4"}}, "./video/a.MP4", false},
+		{&CacheFilter{ExcludeExtensions: []string{"mp4"}}, "./", true},
+		{&CacheFilter{AllowOrigins: []string{"https://cdn.example.com/"}}, "https://cdn.example.com/a.js", true},
+		{&CacheFilter{AllowOrigins: []string{"https://cdn.example.com"}}, "https://other.example.com/a.js", false},
+		{&CacheFilter{AllowOrigins: []string{"https://cdn.example.com"}}, "./a.js", true},
+		{&CacheFilter{AllowOrigins: []string{"https://cdn.example.com"}}, "https://EXAMPLE.com/a.js", true},
+		{&CacheFilter{DenyOrigins: []string{"https://www.google-analytics.com"}}, "https://www.google-analytics.com/analytics.js", false},
+		{&CacheFilter{DenyOrigins: []string{"https://example.com"}}, "./a.js", false},
+		{&CacheFilter{}, "%zz", false},
+	}
+
+	for _, test := range tests {
+
+		allowed := test.filter.Allow(test.uri, self)
+
+		if allowed != test.expected {
+			t.Fatalf("Expected %s to be allowed (%t) by %+v, got %t", test.uri, test.expected, test.filter, allowed)
+		}
+	}
+}
+
+func TestCacheFilterApply(t *testing.T) {
+
+	uris := []string{"./", "./a.png", "./b.css", "./c.png", "https://cdn.example.com/d.js"}
+
+	tests := []struct {
+		filter   *CacheFilter
+		expected []string
+	}{
+		{NewCacheFilter(), uris},
+		{&CacheFilter{MaxEntries: 2}, []string{"./", "./a.png"}},
+		{&CacheFilter{MaxEntries: 10}, uris},
+		{&CacheFilter{Exclude: []string{"*.png"}, MaxEntries: 2}, []string{"./", "./b.css"}},
+		{&CacheFilter{Include: []string{"*.png"}, MaxEntries: 1}, []string{"./a.png"}},
+		{&CacheFilter{DenyOrigins: []string{"https://cdn.example.com"}}, []string{"./", "./a.png", "./b.css", "./c.png"}},
+		{&CacheFilter{Include: []string{"*.gif"}}, []string{}},
+	}
+
+	for _, test := range tests {
+
+		filtered := test.filter.Apply(uris, "https://example.com")
+
+		if strings.Join(filtered, " ") != strings.Join(test.expected, " ") {
+			t.Fatalf("Expected %v to be filtered by %+v as %v, got %v", uris, test.filter, test.expected, filtered)
+		}
+	}
+}
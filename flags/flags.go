@@ -0,0 +1,182 @@
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sfomuseum/go-html-offline"
+	wofflags "github.com/whosonfirst/go-whosonfirst-cli/flags"
+	"regexp"
+	"strings"
+)
+
+// CacheListFlags are the command line flags, shared by the tools in cmd, that
+// control which srcset candidates, links and cross-origin URLs end up in a
+// cache list and how the list is filtered.
+type CacheListFlags struct {
+	SrcsetPolicy           string
+	SrcsetTargetWidth      int
+	SrcsetTargetDensity    float64
+	SrcsetViewportWidth    int
+	LinkRels               wofflags.MultiString
+	CORSOrigins            wofflags.MultiString
+	CrossOriginMode        string
+	CrossOriginCredentials string
+	OpaquePolicy           string
+	Include                wofflags.MultiString
+	Exclude                wofflags.MultiString
+	IncludeRegexps         wofflags.MultiString
+	ExcludeRegexps         wofflags.MultiString
+	IncludeExtensions      wofflags.MultiString
+	ExcludeExtensions      wofflags.MultiString
+	AllowOrigins           wofflags.MultiString
+	DenyOrigins            wofflags.MultiString
+	MaxEntries             int
+}
+
+// AppendCacheListFlags defines the cache list flags in fs and returns the
+// CacheListFlags they are parsed in to.
+func AppendCacheListFlags(fs *flag.FlagSet) *CacheListFlags {
+
+	f := &CacheListFlags{}
+
+	fs.StringVar(&f.SrcsetPolicy, "srcset-policy", offline.SrcsetAll, "How to choose which srcset candidates to cache. Valid options are: all, largest, smallest, nearest-width, nearest-density.")
+	fs.IntVar(&f.SrcsetTargetWidth, "srcset-target-width", 0, "The target width, in CSS pixels, for the nearest-width srcset policy. Default is the width of the slot the image is displayed in.")
+	fs.Float64Var(&f.SrcsetTargetDensity, "srcset-target-density", 1.0, "The target pixel density for the nearest-density srcset policy.")
+	fs.IntVar(&f.SrcsetViewportWidth, "srcset-viewport-width", 1280, "The viewport width, in CSS pixels, that sizes attributes are evaluated against.")
+
+	fs.Var(&f.LinkRels, "link-rel", "One or more <link rel=\"...\"> values to derive URLs from. Default is to use offline.DefaultLinkRels().")
+
+	fs.Var(&f.CORSOrigins, "cors-origin", "One or more origins that send CORS headers and should be requested using mode \"cors\"")
+	fs.StringVar(&f.CrossOriginMode, "cross-origin-mode", "no-cors", "The request mode for cross-origin URLs whose origin is not listed by a -cors-origin flag. Valid options are: cors, no-cors.")
+	fs.StringVar(&f.CrossOriginCredentials, "cross-origin-credentials", "omit", "The credentials mode for cross-origin requests. Valid options are: omit, same-origin, include.")
+	fs.StringVar(&f.OpaquePolicy, "opaque-policy", offline.OpaqueDefer, "How to handle cross-origin URLs that will yield opaque responses. Valid options are: cache, skip, defer.")
+
+	fs.Var(&f.Include, "include", "One or more glob patterns. If present only URLs matching at least one include rule are cached.")
+	fs.Var(&f.Exclude, "exclude", "One or more glob patterns for URLs to exclude from the service worker cache list")
+	fs.Var(&f.IncludeRegexps, "include-regexp", "One or more regular expressions. If present only URLs matching at least one include rule are cached.")
+	fs.Var(&f.ExcludeRegexps, "exclude-regexp", "One or more regular expressions for URLs to exclude from the service worker cache list")
+	fs.Var(&f.IncludeExtensions, "include-extension", "One or more file extensions. If present only URLs matching at least one include rule are cached.")
+	fs.Var(&f.ExcludeExtensions, "exclude-extension", "One or more file extensions for URLs to exclude from the service worker cache list")
+	fs.Var(&f.AllowOrigins, "allow-origin", "One or more origins (for example https://example.com). If present cross-origin URLs are only cached if their origin is listed.")
+	fs.Var(&f.DenyOrigins, "deny-origin", "One or more origins whose URLs are excluded from the service worker cache list")
+	fs.IntVar(&f.MaxEntries, "max-entries", 0, "The maximum number of URLs in the service worker cache list. Zero means no limit.")
+
+	return f
+}
+
+// SplitCommas splits every value of each repeatable flag on commas. It is
+// meant for flags set from environment variables, which can only hold a
+// single value. Regular expressions containing commas can not be set this way.
+func (f *CacheListFlags) SplitCommas() {
+
+	for _, values := range []*wofflags.MultiString{
+		&f.LinkRels, &f.CORSOrigins,
+		&f.Include, &f.Exclude,
+		&f.IncludeRegexps, &f.ExcludeRegexps,
+		&f.IncludeExtensions, &f.ExcludeExtensions,
+		&f.AllowOrigins, &f.DenyOrigins,
+	} {
+
+		split := make(wofflags.MultiString, 0)
+
+		for _, v := range *values {
+
+			for _, part := range strings.Split(v, ",") {
+
+				part = strings.TrimSpace(part)
+
+				if part != "" {
+					split = append(split, part)
+				}
+			}
+		}
+
+		*values = split
+	}
+}
+
+// CacheFilter returns a new CacheFilter for the filtering flags.
+func (f *CacheListFlags) CacheFilter() (*offline.CacheFilter, error) {
+
+	filter := offline.NewCacheFilter()
+	filter.Include = f.Include
+	filter.Exclude = f.Exclude
+	filter.IncludeExtensions = f.IncludeExtensions
+	filter.ExcludeExtensions = f.ExcludeExtensions
+	filter.AllowOrigins = f.AllowOrigins
+	filter.DenyOrigins = f.DenyOrigins
+	filter.MaxEntries = f.MaxEntries
+
+	for _, str_re := range f.IncludeRegexps {
+
+		re, err := regexp.Compile(str_re)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filter.IncludeRegexps = append(filter.IncludeRegexps, re)
+	}
+
+	for _, str_re := range f.ExcludeRegexps {
+
+		re, err := regexp.Compile(str_re)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filter.ExcludeRegexps = append(filter.ExcludeRegexps, re)
+	}
+
+	return filter, nil
+}
+
+// Apply validates the flags and assigns them to the corresponding properties
+// of opts.
+func (f *CacheListFlags) Apply(opts *offline.ServiceWorkerOptions) error {
+
+	if !offline.IsValidSrcsetPolicy(f.SrcsetPolicy) {
+		return fmt.Errorf("Invalid -srcset-policy")
+	}
+
+	if !offline.IsValidCrossOriginMode(f.CrossOriginMode) {
+		return fmt.Errorf("Invalid -cross-origin-mode")
+	}
+
+	if !offline.IsValidCredentials(f.CrossOriginCredentials) {
+		return fmt.Errorf("Invalid -cross-origin-credentials")
+	}
+
+	if !offline.IsValidOpaquePolicy(f.OpaquePolicy) {
+		return fmt.Errorf("Invalid -opaque-policy")
+	}
+
+	if f.MaxEntries < 0 {
+		return fmt.Errorf("Invalid -max-entries")
+	}
+
+	filter, err := f.CacheFilter()
+
+	if err != nil {
+		return err
+	}
+
+	opts.SrcsetPolicy = f.SrcsetPolicy
+	opts.SrcsetTargetWidth = f.SrcsetTargetWidth
+	opts.SrcsetTargetDensity = f.SrcsetTargetDensity
+	opts.SrcsetViewportWidth = f.SrcsetViewportWidth
+
+	if len(f.LinkRels) > 0 {
+		opts.LinkRels = f.LinkRels
+	}
+
+	opts.CORSOrigins = f.CORSOrigins
+	opts.CrossOriginMode = f.CrossOriginMode
+	opts.CrossOriginCredentials = f.CrossOriginCredentials
+	opts.OpaquePolicy = f.OpaquePolicy
+
+	opts.Filter = filter
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package flags
+
+import (
+	"flag"
+	"github.com/sfomuseum/go-html-offline"
+	"strings"
+	"testing"
+)
+
+func TestCacheListFlags(t *testing.T) {
+
+	tests := []struct {
+		args     []string
+		uris     []string
+		expected []string
+		ok       bool
+	}{
+		{[]string{}, []string{"./a.png", "./b.css"}, []string{"./a.png", "./b.css"}, true},
+		{[]string{"-exclude", "*.png"}, []string{"./a.png", "./b.css"}, []string{"./b.css"}, true},
+		{[]string{"-exclude", "*.png,*.css"}, []string{"./a.png", "./b.css", "./c.js"}, []string{"./c.js"}, true},
+		{[]string{"-include-extension", "png, js"}, []string{"./a.png", "./b.css", "./c.js"}, []string{"./a.png", "./c.js"}, true},
+		{[]string{"-exclude-regexp", `\.css$`}, []string{"./a.png", "./b.css"}, []string{"./a.png"}, true},
+		{[]string{"-max-entries", "1"}, []string{"./a.png", "./b.css"}, []string{"./a.png"}, true},
+		{[]string{"-exclude-regexp", "("}, nil, nil, false},
+		{[]string{"-max-entries", "-1"}, nil, nil, false},
+		{[]string{"-srcset-policy", "biggest"}, nil, nil, false},
+		{[]string{"-cross-origin-mode", "same-origin"}, nil, nil, false},
+		{[]string{"-cross-origin-credentials", "never"}, nil, nil, false},
+		{[]string{"-opaque-policy", "ignore"}, nil, nil, false},
+	}
+
+	for _, test := range tests {
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := AppendCacheListFlags(fs)
+
+		err := fs.Parse(test.args)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.SplitCommas()
+
+		opts := offline.DefaultServiceWorkerOptions()
+		err = f.Apply(opts)
+
+		if test.ok && err != nil {
+			t.Fatalf("Failed to apply %v, %v", test.args, err)
+		}
+
+		if !test.ok {
+
+			if err == nil {
+				t.Fatalf("Expected %v to fail", test.args)
+			}
+
+			continue
+		}
+
+		filtered := opts.Filter.Apply(test.uris, "")
+
+		if strings.Join(filtered, " ") != strings.Join(test.expected, " ") {
+			t.Fatalf("Expected %v to filter %v as %v, got %v", test.args, test.uris, test.expected, filtered)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package offline
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+// followDocument fetches the same-origin HTML document href, relative to base,
+// and returns the URIs derived from its elements. It returns false if href is
+// cross-origin or can not be read.
+func followDocument(base *url.URL, href string, resolver *Resolver, opts *ServiceWorkerOptions, depth int, seen map[string]bool) ([]*Asset, bool) {
+
+	doc_url, ok := resolver.ResolveURL(base, href)
+
+	if !ok || !resolver.IsSameOrigin(doc_url) {
+		return nil, false
+	}
+
+	doc_url, body, ok := fetchReference(nil, doc_url.String(), resolver, seen)
+
+	if !ok {
+		return nil, false
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+
+	if err != nil {
+		return nil, false
+	}
+
+	frame_resolver := resolver.WithLocation(doc_url)
+	frame_resolver.SetBaseFromDocument(doc)
+
+	lines := sourceLines(body, doc)
+
+	to_cache, err := inventoryDocument(doc, lines, frame_resolver, opts, seen, depth)
+
+	if err != nil {
+		return nil, false
+	}
+
+	return to_cache, true
+}
+
+// followSrcdoc returns the URIs derived from the elements in the HTML document
+// defined by an <iframe srcdoc="..."> attribute. Relative URIs are resolved
+// against the parent document's base URL. line is the line number of the
+// <iframe> element in the parent document and is recorded for every asset.
+func followSrcdoc(srcdoc string, line int, resolver *Resolver, opts *ServiceWorkerOptions, depth int, seen map[string]bool) []*Asset {
+
+	doc, err := html.Parse(strings.NewReader(srcdoc))
+
+	if err != nil {
+		return []*Asset{}
+	}
+
+	lines := make(map[*html.Node]int)
+	fillLines(lines, line, doc)
+
+	frame_resolver := resolver.WithLocation(resolver.Base())
+	frame_resolver.SetBaseFromDocument(doc)
+
+	to_cache, err := inventoryDocument(doc, lines, frame_resolver, opts, seen, depth)
+
+	if err != nil {
+		return []*Asset{}
+	}
+
+	return to_cache
+}
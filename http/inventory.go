@@ -53,7 +53,7 @@ func InventoryHandler(inv_opts *InventoryOptions, sw_opts *offline.ServiceWorker
 		var buf bytes.Buffer
 		wr := bufio.NewWriter(&buf)
 
-		err = offline.AddServiceWorker(rsp2.Body, ioutil.Discard, wr, sw_opts)
+		err = offline.AddServiceWorkerWithLocation(rsp2.Body, url, ioutil.Discard, wr, sw_opts)
 
 		if err != nil {
 			gohttp.Error(rsp, err.Error(), gohttp.StatusInternalServerError)
@@ -0,0 +1,301 @@
+package offline
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var re_js_import *regexp.Regexp
+var re_js_export *regexp.Regexp
+var re_js_dynamic_import *regexp.Regexp
+
+func init() {
+
+	re_js_import = regexp.MustCompile(`(?:^|[^\w$.])import\s*(?:[\w$*{}\s,]+?\s*from\s*)?(?:"([^"\n]+)"|'([^'\n]+)')`)
+	re_js_export = regexp.MustCompile(`(?:^|[^\w$.])export\s*(?:\*(?:\s*as\s+[\w$]+)?|\{[^}]*\})\s*from\s*(?:"([^"\n]+)"|'([^'\n]+)')`)
+	re_js_dynamic_import = regexp.MustCompile("(?:^|[^\\w$.])import\\(\\s*(?:\"([^\"\\n]+)\"|'([^'\\n]+)'|`([^`$]+)`)\\s*\\)")
+}
+
+// IsJavaScriptType returns true if type_attr, the value of a <script> element's
+// type attribute, is one that browsers will execute. An empty string is
+// treated as a classic script.
+func IsJavaScriptType(type_attr string) bool {
+
+	t := strings.ToLower(strings.TrimSpace(type_attr))
+
+	if idx := strings.Index(t, ";"); idx != -1 {
+		t = strings.TrimSpace(t[:idx])
+	}
+
+	switch t {
+	case "", "module",
+		"application/ecmascript", "application/javascript", "application/x-ecmascript", "application/x-javascript",
+		"text/ecmascript", "text/javascript", "text/javascript1.0", "text/javascript1.1", "text/javascript1.2",
+		"text/javascript1.3", "text/javascript1.4", "text/javascript1.5", "text/jscript", "text/livescript",
+		"text/x-ecmascript", "text/x-javascript":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsModuleType returns true if type_attr, the value of a <script> element's
+// type attribute, denotes a JavaScript module.
+func IsModuleType(type_attr string) bool {
+	return strings.ToLower(strings.TrimSpace(type_attr)) == "module"
+}
+
+// ModuleSpecifiers returns the static import and export ... from specifiers and
+// the string-literal dynamic import() specifiers in the JavaScript module src.
+// Bare specifiers (for example "lodash") are not included since they can only
+// be resolved using an import map.
+func ModuleSpecifiers(src string) []string {
+
+	src, code := stripJavaScriptComments(src)
+
+	specifiers := make([]string, 0)
+	offsets := make([]int, 0)
+
+	for _, re := range []*regexp.Regexp{re_js_import, re_js_export, re_js_dynamic_import} {
+
+		for _, m := range re.FindAllStringSubmatchIndex(src, -1) {
+
+			// ignore things that look like imports inside string literals
+
+			keyword := m[0] + strings.IndexAny(src[m[0]:], "ie")
+
+			if code[keyword] == ' ' {
+				continue
+			}
+
+			spec := firstSubmatch(src, m)
+
+			if !isURLSpecifier(spec) {
+				continue
+			}
+
+			specifiers = append(specifiers, spec)
+			offsets = append(offsets, m[0])
+		}
+	}
+
+	// return specifiers in the order they appear in src
+
+	for i := 1; i < len(offsets); i++ {
+
+		for j := i; j > 0 && offsets[j] < offsets[j-1]; j-- {
+			offsets[j], offsets[j-1] = offsets[j-1], offsets[j]
+			specifiers[j], specifiers[j-1] = specifiers[j-1], specifiers[j]
+		}
+	}
+
+	return specifiers
+}
+
+func isURLSpecifier(spec string) bool {
+
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "/") || strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		return true
+	}
+
+	u, err := url.Parse(spec)
+
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// stripJavaScriptComments replaces comments in src with whitespace, taking
+// care not to treat the contents of string and regular expression literals as
+// comments. It also returns a copy of the result with the contents of string
+// and regular expression literals replaced with whitespace.
+func stripJavaScriptComments(src string) (string, string) {
+
+	out := []byte(src)
+	code := []byte(src)
+
+	blank := func(i int) {
+
+		if out[i] != '\n' {
+			out[i] = ' '
+			code[i] = ' '
+		}
+	}
+
+	var quote byte
+
+	for i := 0; i < len(out); i++ {
+
+		c := out[i]
+
+		if quote != 0 {
+
+			switch {
+			case c == '\\':
+
+				code[i] = ' '
+
+				if i+1 < len(out) {
+					i += 1
+					code[i] = ' '
+				}
+
+			case c == quote:
+				quote = 0
+			default:
+				code[i] = ' '
+			}
+
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+
+			quote = c
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				blank(i)
+			}
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+
+			end := strings.Index(string(out[i+2:]), "*/")
+
+			if end == -1 {
+				end = len(out)
+			} else {
+				end = i + 2 + end + 2
+			}
+
+			for ; i < end; i++ {
+				blank(i)
+			}
+
+			i -= 1
+
+		case c == '/' && isRegexpStart(out, i):
+
+			i = skipRegexp(out, code, i)
+		}
+	}
+
+	return string(out), string(code)
+}
+
+// isRegexpStart returns true if the "/" at offset i in src starts a regular
+// expression literal rather than being a division operator. That is the case
+// when it follows an operator, punctuation that can't end an expression or a
+// keyword like return.
+func isRegexpStart(src []byte, i int) bool {
+
+	j := i - 1
+
+	for j >= 0 && (src[j] == ' ' || src[j] == '\t' || src[j] == '\n' || src[j] == '\r') {
+		j -= 1
+	}
+
+	if j < 0 {
+		return true
+	}
+
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", src[j]) != -1 {
+		return true
+	}
+
+	end := j + 1
+
+	for j >= 0 && isIdentifierByte(src[j]) {
+		j -= 1
+	}
+
+	switch string(src[j+1 : end]) {
+	case "return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw", "case", "do", "else", "yield", "await":
+		return true
+	default:
+		return false
+	}
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// skipRegexp blanks (in code) the body of the regular expression literal that
+// starts at offset i in src and returns the offset of its closing "/". A "/"
+// inside a character class or escaped with a backslash doesn't close it. If
+// there is no closing "/" on the same line i is returned unchanged.
+func skipRegexp(src []byte, code []byte, i int) int {
+
+	in_class := false
+
+	for j := i + 1; j < len(src); j++ {
+
+		switch c := src[j]; {
+		case c == '\n':
+			return i
+		case c == '\\':
+			j += 1
+		case c == '[':
+			in_class = true
+		case c == ']':
+			in_class = false
+		case c == '/' && !in_class:
+
+			for k := i + 1; k < j; k++ {
+				code[k] = ' '
+			}
+
+			return j
+		}
+	}
+
+	return i
+}
+
+// followModule fetches the JavaScript module href, relative to base, and
+// returns every module in its import graph resolved against the location of
+// the module that imports it. Modules are followed until opts.ModuleDepth is
+// reached.
+func followModule(base *url.URL, href string, resolver *Resolver, opts *ServiceWorkerOptions, depth int, seen map[string]bool) []*Asset {
+
+	to_cache := make([]*Asset, 0)
+
+	if depth > opts.ModuleDepth {
+		return to_cache
+	}
+
+	module_url, body, ok := fetchReference(base, href, resolver, seen)
+
+	if !ok {
+		return to_cache
+	}
+
+	for _, spec := range ModuleSpecifiers(string(body)) {
+
+		uri, ok := resolver.ResolveReference(module_url, spec)
+
+		if !ok {
+			continue
+		}
+
+		a := &Asset{
+			URL:    uri,
+			Value:  spec,
+			Kind:   KindScript,
+			Source: module_url.String(),
+		}
+
+		to_cache = append(to_cache, a)
+		to_cache = append(to_cache, followModule(module_url, spec, resolver, opts, depth+1, seen)...)
+	}
+
+	return to_cache
+}
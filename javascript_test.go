@@ -0,0 +1,49 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestModuleSpecifiers(t *testing.T) {
+
+	tests := []struct {
+		src      string
+		expected []string
+	}{
+		{"", []string{}},
+		{`import "./a.js";`, []string{"./a.js"}},
+		{`import b from './b.js'; import { c, d } from "../c.js";`, []string{"./b.js", "../c.js"}},
+		{`import * as e from "/e.js"`, []string{"/e.js"}},
+		{`export * from "./f.js"; export { g } from './g.js';`, []string{"./f.js", "./g.js"}},
+		{"const h = await import('./h.js'); import(`./i.js`);", []string{"./h.js", "./i.js"}},
+		{`import lodash from "lodash"; import x from "https://cdn.example.com/x.js"`, []string{"https://cdn.example.com/x.js"}},
+		{"// import a from './no.js'\n/* import('./no.js') */ import y from './y.js'", []string{"./y.js"}},
+		{`var s = "import z from './no.js'";`, []string{}},
+		{"import(`./${name}.js`)", []string{}},
+		{"obj.import('./no.js')", []string{}},
+		// regular expression literals containing quotes and slashes
+		{"const re = /\"/;\nimport j from \"./j.js\"", []string{"./j.js"}},
+		{"if (/[/'\"]/.test(s)) { import('./k.js') }", []string{"./k.js"}},
+		{"return /'/g.test(s) ? import('./l.js') : null", []string{"./l.js"}},
+		{"var r = /import m from '.\\/no.js'/; import n from './n.js'", []string{"./n.js"}},
+		// division isn't a regular expression
+		{"var q = a / b; import o from './o.js' // c / d", []string{"./o.js"}},
+		{"var q = (a) / 2, p = \"/\"; import p from './p.js'", []string{"./p.js"}},
+	}
+
+	for _, test := range tests {
+
+		specs := ModuleSpecifiers(test.src)
+
+		if len(specs) != len(test.expected) {
+			t.Fatalf("Expected %v for '%s', got %v", test.expected, test.src, specs)
+		}
+
+		for idx, spec := range specs {
+
+			if spec != test.expected[idx] {
+				t.Fatalf("Expected %v for '%s', got %v", test.expected, test.src, specs)
+			}
+		}
+	}
+}
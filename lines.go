@@ -0,0 +1,100 @@
+package offline
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// sourceLines returns the line number that each element in nodes (and their
+// descendants) starts on in body, the source they were parsed from. The parse
+// tree doesn't record positions so start tags are matched, by name and in
+// order, against the tokens in body. Elements the parser adds implicitly (or
+// moves) may be assigned the wrong line or none at all.
+func sourceLines(body []byte, nodes ...*html.Node) map[*html.Node]int {
+
+	tags := make(map[string][]int)
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	line := 1
+
+	for {
+
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			break
+		}
+
+		newlines := bytes.Count(z.Raw(), []byte("\n"))
+
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			name, _ := z.TagName()
+			tags[string(name)] = append(tags[string(name)], line)
+		}
+
+		line += newlines
+	}
+
+	lines := make(map[*html.Node]int)
+
+	var walk func(n *html.Node)
+
+	walk = func(n *html.Node) {
+
+		if n.Type == html.ElementNode {
+
+			name := strings.ToLower(n.Data)
+			queue := tags[name]
+
+			if len(queue) > 0 {
+				lines[n] = queue[0]
+				tags[name] = queue[1:]
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	return lines
+}
+
+// offsetLines adds offset to every line number in lines.
+func offsetLines(lines map[*html.Node]int, offset int) map[*html.Node]int {
+
+	for n, line := range lines {
+		lines[n] = line + offset
+	}
+
+	return lines
+}
+
+// fillLines records line as the line number for every element in nodes (and
+// their descendants) which isn't already present in lines.
+func fillLines(lines map[*html.Node]int, line int, nodes ...*html.Node) {
+
+	var walk func(n *html.Node)
+
+	walk = func(n *html.Node) {
+
+		_, ok := lines[n]
+
+		if n.Type == html.ElementNode && !ok {
+			lines[n] = line
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	for _, n := range nodes {
+		walk(n)
+	}
+}
@@ -0,0 +1,102 @@
+package offline
+
+// https://developer.mozilla.org/en-US/docs/Web/Manifest
+// https://www.w3.org/TR/appmanifest/
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+type manifestImage struct {
+	Src string `json:"src"`
+}
+
+type manifestShortcut struct {
+	URL   string          `json:"url"`
+	Icons []manifestImage `json:"icons"`
+}
+
+type manifest struct {
+	StartURL    string             `json:"start_url"`
+	Icons       []manifestImage    `json:"icons"`
+	Screenshots []manifestImage    `json:"screenshots"`
+	Shortcuts   []manifestShortcut `json:"shortcuts"`
+}
+
+// ManifestReferences returns the start_url and the icons, screenshots and
+// shortcut icons listed in a web app manifest, in that order, as they appear
+// in the manifest.
+func ManifestReferences(body []byte) ([]string, error) {
+
+	var m manifest
+
+	err := json.Unmarshal(body, &m)
+
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]string, 0)
+
+	if m.StartURL != "" {
+		refs = append(refs, m.StartURL)
+	}
+
+	for _, i := range m.Icons {
+		refs = append(refs, i.Src)
+	}
+
+	for _, i := range m.Screenshots {
+		refs = append(refs, i.Src)
+	}
+
+	for _, s := range m.Shortcuts {
+
+		for _, i := range s.Icons {
+			refs = append(refs, i.Src)
+		}
+	}
+
+	return refs, nil
+}
+
+// followManifest fetches the web app manifest href, relative to base, and
+// returns every resource it references resolved against the manifest's own
+// location.
+func followManifest(base *url.URL, href string, resolver *Resolver, opts *ServiceWorkerOptions, seen map[string]bool) []*Asset {
+
+	to_cache := make([]*Asset, 0)
+
+	manifest_url, body, ok := fetchReference(base, href, resolver, seen)
+
+	if !ok {
+		return to_cache
+	}
+
+	refs, err := ManifestReferences(body)
+
+	if err != nil {
+		return to_cache
+	}
+
+	for _, ref := range refs {
+
+		uri, ok := resolver.ResolveReference(manifest_url, ref)
+
+		if !ok {
+			continue
+		}
+
+		a := &Asset{
+			URL:    uri,
+			Value:  ref,
+			Kind:   InferKind("", "", uri),
+			Source: manifest_url.String(),
+		}
+
+		to_cache = append(to_cache, a)
+	}
+
+	return to_cache
+}
@@ -0,0 +1,51 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestManifestReferences(t *testing.T) {
+
+	tests := []struct {
+		body     string
+		expected []string
+		ok       bool
+	}{
+		{`{}`, []string{}, true},
+		{`{"name": "Example", "start_url": "./index.html"}`, []string{"./index.html"}, true},
+		{`{"start_url": "/", "icons": [{"src": "icon-192.png", "sizes": "192x192"}, {"src": "icon-512.png"}]}`, []string{"/", "icon-192.png", "icon-512.png"}, true},
+		{`{"screenshots": [{"src": "shot.jpg"}], "icons": [{"src": "icon.png"}], "start_url": "."}`, []string{".", "icon.png", "shot.jpg"}, true},
+		{`{"shortcuts": [{"url": "/today", "icons": [{"src": "today.png"}]}, {"url": "/tomorrow"}]}`, []string{"today.png"}, true},
+		{`{"start_url": "./", "icons": "icon.png"}`, nil, false},
+		{`not json`, nil, false},
+	}
+
+	for _, test := range tests {
+
+		refs, err := ManifestReferences([]byte(test.body))
+
+		if !test.ok {
+
+			if err == nil {
+				t.Fatalf("Expected '%s' to fail, got %v", test.body, refs)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", test.body, err)
+		}
+
+		if len(refs) != len(test.expected) {
+			t.Fatalf("Expected %v for '%s', got %v", test.expected, test.body, refs)
+		}
+
+		for idx, ref := range refs {
+
+			if ref != test.expected[idx] {
+				t.Fatalf("Expected %v for '%s', got %v", test.expected, test.body, refs)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package offline
+
+// https://ogp.me/
+// https://developer.twitter.com/en/docs/twitter-for-websites/cards/overview/markup
+// https://json-ld.org/
+
+import (
+	"encoding/json"
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+)
+
+var metadata_properties = map[string]bool{
+	"og:image":            true,
+	"og:image:url":        true,
+	"og:image:secure_url": true,
+	"twitter:image":       true,
+	"twitter:image:src":   true,
+}
+
+var jsonld_properties = map[string]bool{
+	"image":        true,
+	"thumbnailUrl": true,
+}
+
+// MetadataExtractor derives references from Open Graph and Twitter card <meta>
+// elements and from the image and thumbnailUrl properties in JSON-LD <script>
+// elements. It does nothing unless opts.Metadata is true.
+type MetadataExtractor struct{}
+
+func (e *MetadataExtractor) Extract(n *html.Node, opts *ServiceWorkerOptions) ([]*Reference, error) {
+
+	refs := make([]*Reference, 0)
+
+	if !opts.Metadata {
+		return refs, nil
+	}
+
+	switch n.Data {
+
+	case "meta":
+
+		meta := attrs2map(n.Attr...)
+
+		content, content_ok := meta["content"]
+
+		if !content_ok {
+			break
+		}
+
+		for _, attr := range []string{"property", "name"} {
+
+			if metadata_properties[strings.ToLower(strings.TrimSpace(meta[attr]))] {
+				refs = append(refs, &Reference{URI: content, Element: n.Data, Attribute: "content", Kind: KindImage})
+				break
+			}
+		}
+
+	case "script":
+
+		if !strings.EqualFold(strings.TrimSpace(attrs2map(n.Attr...)["type"]), "application/ld+json") {
+			break
+		}
+
+		var body strings.Builder
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+			if c.Type == html.TextNode {
+				body.WriteString(c.Data)
+			}
+		}
+
+		for _, uri := range JSONLDReferences([]byte(body.String())) {
+			refs = append(refs, &Reference{URI: uri, Element: n.Data, Attribute: "", Kind: KindImage})
+		}
+	}
+
+	return refs, nil
+}
+
+// JSONLDReferences returns the URLs listed in the image and thumbnailUrl
+// properties, at any depth, of a JSON-LD document. Invalid JSON yields an
+// empty list.
+func JSONLDReferences(body []byte) []string {
+
+	var doc interface{}
+
+	err := json.Unmarshal(body, &doc)
+
+	if err != nil {
+		return []string{}
+	}
+
+	return jsonldWalk(doc, false)
+}
+
+func jsonldWalk(v interface{}, is_image bool) []string {
+
+	uris := make([]string, 0)
+
+	switch v := v.(type) {
+
+	case string:
+
+		if is_image {
+			uris = append(uris, v)
+		}
+
+	case []interface{}:
+
+		for _, item := range v {
+			uris = append(uris, jsonldWalk(item, is_image)...)
+		}
+
+	case map[string]interface{}:
+
+		// an ImageObject (or similar) as the value of an image property
+
+		if is_image {
+
+			for _, k := range []string{"url", "contentUrl", "@id"} {
+
+				str, ok := v[k].(string)
+
+				if ok {
+					uris = append(uris, str)
+					break
+				}
+			}
+		}
+
+		// sort keys so that the order of the results is stable
+
+		keys := make([]string, 0)
+
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+
+			item := v[k]
+
+			if jsonld_properties[k] {
+				uris = append(uris, jsonldWalk(item, true)...)
+				continue
+			}
+
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				uris = append(uris, jsonldWalk(item, false)...)
+			}
+		}
+	}
+
+	return uris
+}
@@ -0,0 +1,40 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestJSONLDReferences(t *testing.T) {
+
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{`{}`, []string{}},
+		{`not json`, []string{}},
+		{`{"@type": "Article", "url": "https://example.com/a", "image": "hero.jpg"}`, []string{"hero.jpg"}},
+		{`{"@type": "Article", "image": ["a.jpg", "b.jpg"], "thumbnailUrl": "thumb.jpg"}`, []string{"a.jpg", "b.jpg", "thumb.jpg"}},
+		{`{"@type": "Article", "image": {"@type": "ImageObject", "url": "hero.jpg", "width": 1200}}`, []string{"hero.jpg"}},
+		{`{"image": {"@type": "ImageObject", "contentUrl": "content.jpg", "thumbnailUrl": "thumb.jpg"}}`, []string{"content.jpg", "thumb.jpg"}},
+		{`{"image": {"@type": "ImageObject", "@id": "https://example.com/#hero"}}`, []string{"https://example.com/#hero"}},
+		{`{"@graph": [{"@type": "WebPage", "primaryImageOfPage": {"image": {"@type": "ImageObject", "url": "nested.jpg"}}}, {"@type": "Person", "image": "person.jpg"}]}`, []string{"nested.jpg", "person.jpg"}},
+		{`[{"image": "a.jpg"}, {"logo": {"url": "logo.png"}}]`, []string{"a.jpg"}},
+		{`{"image": 42, "name": "image.jpg"}`, []string{}},
+	}
+
+	for _, test := range tests {
+
+		refs := JSONLDReferences([]byte(test.body))
+
+		if len(refs) != len(test.expected) {
+			t.Fatalf("Expected %v for '%s', got %v", test.expected, test.body, refs)
+		}
+
+		for idx, ref := range refs {
+
+			if ref != test.expected[idx] {
+				t.Fatalf("Expected %v for '%s', got %v", test.expected, test.body, refs)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package offline
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NormalizeURI returns a canonical form of uri: fragments are removed, scheme
+// and host names are lower-cased (and international domain names converted to
+// punycode), default ports are removed and percent-encoding is canonicalized.
+// If uri can not be parsed it is returned as-is.
+func NormalizeURI(uri string) string {
+
+	u, err := url.Parse(strings.TrimSpace(uri))
+
+	if err != nil {
+		return uri
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if u.Scheme != "" {
+		u.Scheme = strings.ToLower(u.Scheme)
+	}
+
+	if u.Host != "" {
+
+		host := u.Hostname()
+		port := u.Port()
+
+		host = strings.ToLower(host)
+
+		// hosts which aren't valid domain names (IP addresses, for example)
+		// are left as-is
+
+		ascii_host, err := idna.Lookup.ToASCII(host)
+
+		if err == nil {
+			host = ascii_host
+		}
+
+		if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			port = ""
+		}
+
+		if strings.Contains(host, ":") {
+			host = fmt.Sprintf("[%s]", host)
+		}
+
+		if port != "" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+
+		u.Host = host
+
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	escaped_path := normalizePercentEncoding(u.EscapedPath(), "/")
+	path, err := url.PathUnescape(escaped_path)
+
+	if err == nil {
+		u.Path = path
+		u.RawPath = escaped_path
+	}
+
+	u.RawQuery = normalizePercentEncoding(u.RawQuery, "&=")
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// normalizePercentEncoding upper-cases the hex digits in percent-encoded
+// octets and decodes octets which are unreserved characters (RFC 3986).
+// Encoded octets which would decode to one of the characters in keep are left
+// encoded.
+func normalizePercentEncoding(str string, keep string) string {
+
+	var buf strings.Builder
+
+	for i := 0; i < len(str); i++ {
+
+		c := str[i]
+
+		if c != '%' || i+2 >= len(str) || !isHex(str[i+1]) || !isHex(str[i+2]) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		octet := unhex(str[i+1])<<4 | unhex(str[i+2])
+
+		if isUnreserved(octet) && !strings.ContainsRune(keep, rune(octet)) {
+			buf.WriteByte(octet)
+		} else {
+			buf.WriteString(strings.ToUpper(str[i : i+3]))
+		}
+
+		i += 2
+	}
+
+	return buf.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,39 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestNormalizeURI(t *testing.T) {
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{"./a.png", "./a.png"},
+		{"./a.png#top", "./a.png"},
+		{"HTTPS://Example.COM", "https://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http://example.com:443/a", "http://example.com:443/a"},
+		{"./a%7eb%2fc.png", "./a~b%2Fc.png"},
+		{"./a%2520b.png", "./a%2520b.png"},
+		{"./a.png?q=%7e&r=%26", "./a.png?q=~&r=%26"},
+		{"./a.png?", "./a.png"},
+		{"https://Bücher.example/", "https://xn--bcher-kva.example/"},
+		{"https://ＥＸＡＭＰＬＥ.com/", "https://example.com/"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"http://127.0.0.1:8080/x", "http://127.0.0.1:8080/x"},
+		{" ./spaces.png ", "./spaces.png"},
+	}
+
+	for _, test := range tests {
+
+		normalized := NormalizeURI(test.uri)
+
+		if normalized != test.expected {
+			t.Fatalf("Expected '%s' to normalize to '%s', got '%s'", test.uri, test.expected, normalized)
+		}
+	}
+}
@@ -15,42 +15,227 @@ import (
 	"io"
 	"io/ioutil"
 	_ "log"
-	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
 )
 
+// ServiceWorkerVars are the variables available to the service worker
+// template.
 type ServiceWorkerVars struct {
+	// CacheName is the (versioned) name of the precache.
 	CacheName string
-	ToCache   []string
-	Date      string
+	// CachePrefix is the unversioned name of the precache. It is unique to
+	// the service worker's scope, if known.
+	CachePrefix string
+	// CacheVersion is the version appended to CachePrefix. It may be empty.
+	CacheVersion string
+	// LegacyCacheName is the name of the precache used before caches were
+	// versioned and scoped.
+	LegacyCacheName string
+	// KeepVersions is the number of cache versions to keep on activate.
+	KeepVersions int
+	// SkipWaiting is true if a new service worker activates as soon as it is
+	// installed rather than waiting for every page using the old one to close.
+	SkipWaiting bool
+	// ClientsClaim is true if the service worker takes control of open pages
+	// as soon as it is activated.
+	ClientsClaim bool
+	// NavigationPreload is true if navigation preload is enabled.
+	NavigationPreload bool
+	// PrecacheMode is "strict" or "tolerant".
+	PrecacheMode string
+	// PrecacheRetries is the number of times to retry a failed request in
+	// tolerant mode.
+	PrecacheRetries int
+	// PrecacheRetryDelay is the delay, in milliseconds, before the first
+	// retry. It doubles for each subsequent retry.
+	PrecacheRetryDelay int
+	// ToCache is the list of URIs to precache.
+	ToCache []string
+	// Requests describes how to request each of the URIs in ToCache.
+	Requests []*PrecacheRequest
+	// Assets describes each of the URIs in ToCache.
+	Assets []*Asset
+	// Strategy is the default caching strategy.
+	Strategy string
+	// NetworkTimeout is the default network timeout in milliseconds.
+	NetworkTimeout int
+	// Routes are the per-route caching rules, in order.
+	Routes []*Route
+	// RuntimeCaching is true if responses for requests that aren't in the
+	// precache list are stored in the RuntimeCacheName cache.
+	RuntimeCaching   bool
+	RuntimeCacheName string
+	// Expirations are the limits for each runtime cache, keyed by cache name.
+	Expirations map[string]*CacheExpiration
+	// Fallbacks maps request destinations to fallback URIs.
+	Fallbacks map[string]string
+	// Date is the time the service worker was generated (RFC 3339).
+	Date string
+	// Data is the ServiceWorkerOptions TemplateData property.
+	Data map[string]interface{}
 }
 
+// ServiceWorkerInitVars are the variables available to the template for the
+// script that registers the service worker.
 type ServiceWorkerInitVars struct {
 	ServiceWorkerURL string
-	Date             string
+	// Date is the time the script was generated (RFC 3339).
+	Date string
+	// Data is the ServiceWorkerOptions TemplateData property.
+	Data map[string]interface{}
 }
 
 type ServiceWorkerOptions struct {
-	CacheName        string
-	CacheURLs        []string
-	ServiceWorkerURL string
+	CacheName                string
+	VersionCache             bool
+	CacheVersion             string
+	KeepVersions             int
+	SkipWaiting              bool
+	ClientsClaim             bool
+	NavigationPreload        bool
+	CacheURLs                []string
+	PrecacheMode             string
+	PrecacheRetries          int
+	PrecacheRetryDelay       int
+	Fallbacks                map[string]string
+	ServiceWorkerTemplate    *TemplateSource
+	InitTemplate             *TemplateSource
+	TemplateData             map[string]interface{}
+	ServiceWorkerURL         string
+	Strategy                 string
+	NetworkTimeout           int
+	Routes                   []*Route
+	RuntimeCaching           bool
+	RuntimeCacheName         string
+	RuntimeMaxEntries        int
+	RuntimeMaxAge            int
+	SrcsetPolicy             string
+	SrcsetTargetWidth        int
+	SrcsetTargetDensity      float64
+	SrcsetViewportWidth      int
+	FollowStylesheets        bool
+	StylesheetDepth          int
+	FollowManifests          bool
+	FollowModules            bool
+	ModuleDepth              int
+	FollowFrames             bool
+	FrameDepth               int
+	Metadata                 bool
+	Filter                   *CacheFilter
+	CORSOrigins              []string
+	CrossOriginMode          string
+	CrossOriginCredentials   string
+	OpaquePolicy             string
+	Scope                    string
+	AbsoluteURLs             bool
+	Extractors               []Extractor
+	Noscript                 bool
+	Templates                bool
+	LazyLoad                 bool
+	LinkRels                 []string
+	PreloadAs                []string
+	SkipPrintStylesheets     bool
+	SkipAlternateStylesheets bool
+	// the templates parsed by ValidateTemplates
+	sw_template   *template.Template
+	init_template *template.Template
 }
 
 func DefaultServiceWorkerOptions() *ServiceWorkerOptions {
 
 	opts := ServiceWorkerOptions{
-		CacheName:        "network-or-cache",
-		CacheURLs:        []string{},
-		ServiceWorkerURL: "sw.js",
+		CacheName:                "network-or-cache",
+		VersionCache:             true,
+		CacheVersion:             "",
+		KeepVersions:             1,
+		SkipWaiting:              false,
+		ClientsClaim:             false,
+		NavigationPreload:        false,
+		CacheURLs:                []string{},
+		PrecacheMode:             PrecacheStrict,
+		PrecacheRetries:          3,
+		PrecacheRetryDelay:       500,
+		Fallbacks:                map[string]string{},
+		ServiceWorkerTemplate:    nil,
+		InitTemplate:             nil,
+		TemplateData:             map[string]interface{}{},
+		ServiceWorkerURL:         "sw.js",
+		Strategy:                 StrategyNetworkFirst,
+		NetworkTimeout:           400,
+		Routes:                   []*Route{},
+		RuntimeCaching:           false,
+		RuntimeCacheName:         "runtime",
+		RuntimeMaxEntries:        100,
+		RuntimeMaxAge:            60 * 60 * 24 * 30,
+		SrcsetPolicy:             SrcsetAll,
+		SrcsetTargetWidth:        0,
+		SrcsetTargetDensity:      1.0,
+		SrcsetViewportWidth:      1280,
+		FollowStylesheets:        true,
+		StylesheetDepth:          3,
+		FollowManifests:          true,
+		FollowModules:            true,
+		ModuleDepth:              8,
+		FollowFrames:             false,
+		FrameDepth:               2,
+		Metadata:                 false,
+		Filter:                   NewCacheFilter(),
+		CORSOrigins:              []string{},
+		CrossOriginMode:          "no-cors",
+		CrossOriginCredentials:   "omit",
+		OpaquePolicy:             OpaqueDefer,
+		Scope:                    "",
+		AbsoluteURLs:             false,
+		Extractors:               DefaultExtractors(),
+		Noscript:                 true,
+		Templates:                true,
+		LazyLoad:                 true,
+		LinkRels:                 DefaultLinkRels(),
+		PreloadAs:                DefaultPreloadAs(),
+		SkipPrintStylesheets:     false,
+		SkipAlternateStylesheets: false,
 	}
 
 	return &opts
 }
 
+func DefaultLinkRels() []string {
+
+	return []string{
+		"stylesheet",
+		"icon",
+		"apple-touch-icon",
+		"apple-touch-icon-precomposed",
+		"mask-icon",
+		"manifest",
+		"preload",
+		"prefetch",
+		"modulepreload",
+	}
+}
+
+func DefaultPreloadAs() []string {
+
+	return []string{
+		"audio",
+		"document",
+		"fetch",
+		"font",
+		"image",
+		"script",
+		"style",
+		"track",
+		"video",
+		"worker",
+	}
+}
+
 func AddServiceWorkerToFile(path string, opts *ServiceWorkerOptions) error {
 
 	html_path, err := filepath.Abs(path)
@@ -59,6 +244,12 @@ func AddServiceWorkerToFile(path string, opts *ServiceWorkerOptions) error {
 		return err
 	}
 
+	location, err := fileURL(html_path)
+
+	if err != nil {
+		return err
+	}
+
 	in, err := os.Open(html_path)
 
 	if err != nil {
@@ -81,7 +272,7 @@ func AddServiceWorkerToFile(path string, opts *ServiceWorkerOptions) error {
 		return err
 	}
 
-	err = AddServiceWorker(in, html_out, sw_out, opts)
+	err = AddServiceWorkerWithLocation(in, location, html_out, sw_out, opts)
 
 	if err != nil {
 		html_out.Abort()
@@ -114,31 +305,40 @@ func AddServiceWorkerToFile(path string, opts *ServiceWorkerOptions) error {
 }
 
 func AddServiceWorker(in io.Reader, html_wr io.Writer, serviceworker_wr io.Writer, opts *ServiceWorkerOptions) error {
+	return AddServiceWorkerWithLocation(in, nil, html_wr, serviceworker_wr, opts)
+}
+
+// AddServiceWorkerWithLocation is like AddServiceWorker but takes the location
+// (a file:// or http(s):// URL) of the document being read so that linked
+// resources, like stylesheets, can be inventoried. location may be nil.
+func AddServiceWorkerWithLocation(in io.Reader, location *url.URL, html_wr io.Writer, serviceworker_wr io.Writer, opts *ServiceWorkerOptions) error {
 
-	sw_t, err := template.New("service-worker").Parse(sw)
+	sw_t, init_t, err := opts.templates()
 
 	if err != nil {
 		return err
 	}
 
-	init_t, err := template.New("service-worker-init").Parse(sw_init)
+	body, err := ioutil.ReadAll(in)
 
 	if err != nil {
 		return err
 	}
 
-	doc, err := html.Parse(in)
+	doc, err := html.Parse(bytes.NewReader(body))
 
 	if err != nil {
 		return err
 	}
 
-	to_cache, err := CacheList(doc, opts)
+	inventory, err := cacheInventory(doc, sourceLines(body, doc), location, opts)
 
 	if err != nil {
 		return err
 	}
 
+	var init_err error
+
 	var callback func(node *html.Node, writer io.Writer)
 
 	callback = func(n *html.Node, w io.Writer) {
@@ -169,6 +369,7 @@ func AddServiceWorker(in io.Reader, html_wr io.Writer, serviceworker_wr io.Write
 				vars := ServiceWorkerInitVars{
 					ServiceWorkerURL: opts.ServiceWorkerURL,
 					Date:             now.Format(time.RFC3339),
+					Data:             opts.TemplateData,
 				}
 
 				var buf bytes.Buffer
@@ -177,14 +378,14 @@ func AddServiceWorker(in io.Reader, html_wr io.Writer, serviceworker_wr io.Write
 				err := init_t.Execute(wr, vars)
 
 				if err != nil {
-					// log.Println(err)
+					init_err = err
 					return
 				}
 
 				wr.Flush()
 
-				script_type := html.Attribute{"", "type", "text/javascript"}
-				script_rel := html.Attribute{"", "x-service-worker", "true"}
+				script_type := html.Attribute{Key: "type", Val: "text/javascript"}
+				script_rel := html.Attribute{Key: "x-service-worker", Val: "true"}
 
 				script := html.Node{
 					Type:      html.ElementNode,
@@ -214,12 +415,71 @@ func AddServiceWorker(in io.Reader, html_wr io.Writer, serviceworker_wr io.Write
 
 	callback(doc, html_wr)
 
+	if init_err != nil {
+		return init_err
+	}
+
 	now := time.Now()
 
+	// every service worker on an origin shares the same caches so the name of
+	// the precache includes a hash of the service worker's scope, if known,
+	// to keep service workers in different directories from deleting each
+	// other's caches
+
+	cache_prefix := opts.CacheName
+	scope_id := inventory.ScopeID()
+
+	if scope_id != "" {
+		cache_prefix = fmt.Sprintf("%s-%s", opts.CacheName, scope_id)
+	}
+
+	cache_name := cache_prefix
+	cache_version := ""
+
+	// versioned caches are named "{prefix}-{version}" so that the service
+	// worker can tell which caches were created by earlier versions
+
+	if opts.VersionCache {
+
+		cache_version = opts.CacheVersion
+
+		if cache_version == "" {
+			cache_version = inventory.Version()
+		}
+
+		cache_name = fmt.Sprintf("%s-%s", cache_prefix, cache_version)
+	}
+
+	keep_versions := opts.KeepVersions
+
+	if keep_versions < 1 {
+		keep_versions = 1
+	}
+
 	vars := ServiceWorkerVars{
-		CacheName: opts.CacheName,
-		ToCache:   to_cache,
-		Date:      now.Format(time.RFC3339),
+		CacheName:          cache_name,
+		CachePrefix:        cache_prefix,
+		CacheVersion:       cache_version,
+		LegacyCacheName:    opts.CacheName,
+		KeepVersions:       keep_versions,
+		SkipWaiting:        opts.SkipWaiting,
+		ClientsClaim:       opts.ClientsClaim,
+		NavigationPreload:  opts.NavigationPreload,
+		PrecacheMode:       opts.PrecacheMode,
+		PrecacheRetries:    opts.PrecacheRetries,
+		PrecacheRetryDelay: opts.PrecacheRetryDelay,
+		ToCache:            inventory.URLs(),
+		Requests:           inventory.PrecacheRequests(opts),
+		Assets:             inventory.Assets,
+		Strategy:           opts.Strategy,
+		NetworkTimeout:     opts.NetworkTimeout,
+		Routes:             opts.Routes,
+		RuntimeCaching:     opts.RuntimeCaching,
+		RuntimeCacheName:   opts.RuntimeCacheName,
+		Expirations:        cacheExpirations(opts),
+		Fallbacks:          inventory.Fallbacks,
+		Data:               opts.TemplateData,
+		Date:               now.Format(time.RFC3339),
 	}
 
 	err = sw_t.Execute(serviceworker_wr, vars)
@@ -233,6 +493,63 @@ func AddServiceWorker(in io.Reader, html_wr io.Writer, serviceworker_wr io.Write
 
 func CacheListFromFile(path string, opts *ServiceWorkerOptions) ([]string, error) {
 
+	inventory, err := CacheInventoryFromFile(path, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory.URLs(), nil
+}
+
+func CacheListFromURL(uri string, opts *ServiceWorkerOptions) ([]string, error) {
+
+	inventory, err := CacheInventoryFromURL(uri, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory.URLs(), nil
+}
+
+func CacheListFromReader(fh io.Reader, opts *ServiceWorkerOptions) ([]string, error) {
+
+	inventory, err := CacheInventoryFromReader(fh, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory.URLs(), nil
+}
+
+func CacheList(doc *html.Node, opts *ServiceWorkerOptions) ([]string, error) {
+	return CacheListWithLocation(doc, nil, opts)
+}
+
+// CacheListWithLocation is like CacheList but takes the location of the
+// document so that relative references to linked resources can be followed.
+// location may be nil in which case only absolute references are followed.
+func CacheListWithLocation(doc *html.Node, location *url.URL, opts *ServiceWorkerOptions) ([]string, error) {
+
+	inventory, err := CacheInventoryWithLocation(doc, location, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory.URLs(), nil
+}
+
+func CacheInventoryFromFile(path string, opts *ServiceWorkerOptions) (*Inventory, error) {
+
+	location, err := fileURL(path)
+
+	if err != nil {
+		return nil, err
+	}
+
 	fh, err := os.Open(path)
 
 	if err != nil {
@@ -241,12 +558,18 @@ func CacheListFromFile(path string, opts *ServiceWorkerOptions) ([]string, error
 
 	defer fh.Close()
 
-	return CacheListFromReader(fh, opts)
+	return cacheInventoryFromReader(fh, location, opts)
 }
 
-func CacheListFromURL(url string, opts *ServiceWorkerOptions) ([]string, error) {
+func CacheInventoryFromURL(uri string, opts *ServiceWorkerOptions) (*Inventory, error) {
 
-	rsp, err := http.Get(url)
+	location, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := fetch_client.Get(uri)
 
 	if err != nil {
 		return nil, err
@@ -254,89 +577,324 @@ func CacheListFromURL(url string, opts *ServiceWorkerOptions) ([]string, error)
 
 	defer rsp.Body.Close()
 
-	return CacheListFromReader(rsp.Body, opts)
+	return cacheInventoryFromReader(rsp.Body, location, opts)
 }
 
-func CacheListFromReader(fh io.Reader, opts *ServiceWorkerOptions) ([]string, error) {
+func CacheInventoryFromReader(fh io.Reader, opts *ServiceWorkerOptions) (*Inventory, error) {
+	return cacheInventoryFromReader(fh, nil, opts)
+}
+
+func cacheInventoryFromReader(fh io.Reader, location *url.URL, opts *ServiceWorkerOptions) (*Inventory, error) {
+
+	body, err := ioutil.ReadAll(fh)
+
+	if err != nil {
+		return nil, err
+	}
 
-	doc, err := html.Parse(fh)
+	doc, err := html.Parse(bytes.NewReader(body))
 
 	if err != nil {
 		return nil, err
 	}
 
-	return CacheList(doc, opts)
+	return cacheInventory(doc, sourceLines(body, doc), location, opts)
 }
 
-func CacheList(doc *html.Node, opts *ServiceWorkerOptions) ([]string, error) {
+// CacheInventory is like CacheList but returns an Inventory of assets rather
+// than a list of strings. Since doc has already been parsed the line numbers
+// of assets are unknown; use CacheInventoryFromFile, CacheInventoryFromURL or
+// CacheInventoryFromReader if they are needed.
+func CacheInventory(doc *html.Node, opts *ServiceWorkerOptions) (*Inventory, error) {
+	return CacheInventoryWithLocation(doc, nil, opts)
+}
+
+// CacheInventoryWithLocation is like CacheListWithLocation but returns an
+// Inventory of assets rather than a list of strings.
+func CacheInventoryWithLocation(doc *html.Node, location *url.URL, opts *ServiceWorkerOptions) (*Inventory, error) {
+	return cacheInventory(doc, nil, location, opts)
+}
+
+// cacheInventory returns the inventory for doc. lines maps the elements in
+// doc to their line number in the source document; it may be nil.
+func cacheInventory(doc *html.Node, lines map[*html.Node]int, location *url.URL, opts *ServiceWorkerOptions) (*Inventory, error) {
+
+	resolver, err := NewResolver(location, opts)
 
-	to_cache := []string{
-		"",
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.SetBaseFromDocument(doc)
+
+	scope := resolver.ScopeURI()
+
+	assets := []*Asset{
+		&Asset{URL: scope, Value: scope, Kind: KindDocument},
 	}
 
 	for _, u := range opts.CacheURLs {
-		to_cache = append(to_cache, u)
+		assets = append(assets, &Asset{URL: u, Value: u, Kind: InferKind("", "", u)})
+	}
+
+	// fallbacks are always precached
+
+	fallbacks := make(map[string]*Asset)
+	destinations := make([]string, 0)
+
+	for dest := range opts.Fallbacks {
+		destinations = append(destinations, dest)
+	}
+
+	sort.Strings(destinations)
+
+	for _, dest := range destinations {
+
+		uri := opts.Fallbacks[dest]
+
+		resolved, err := resolveFallback(resolver, uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid fallback for %s requests, %v", dest, err)
+		}
+
+		a := &Asset{
+			URL:   resolved,
+			Value: uri,
+			Kind:  fallbackKind(dest, resolved),
+		}
+
+		fallbacks[dest] = a
+		assets = append(assets, a)
+	}
+
+	seen := make(map[string]bool)
+
+	doc_assets, err := inventoryDocument(doc, lines, resolver, opts, seen, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assets = append(assets, doc_assets...)
+
+	// the first asset for each (normalized) URI is the one that is kept
+
+	first := make(map[string]*Asset)
+	to_cache := make([]string, 0)
+
+	for _, a := range assets {
+
+		if !strings.HasPrefix(a.URL, "/") && !strings.HasPrefix(a.URL, "http") && !strings.HasPrefix(a.URL, "./") && !strings.HasPrefix(a.URL, "../") {
+			a.URL = fmt.Sprintf("./%s", a.URL)
+		}
+
+		a.URL = NormalizeURI(a.URL)
+
+		_, ok := first[a.URL]
+
+		if !ok {
+			first[a.URL] = a
+			to_cache = append(to_cache, a.URL)
+		}
+	}
+
+	if opts.Filter != nil {
+		to_cache = opts.Filter.Apply(to_cache, resolver.Origin())
+	}
+
+	inventory := &Inventory{
+		Origin:    resolver.Origin(),
+		Assets:    make([]*Asset, 0),
+		Fallbacks: make(map[string]string),
+		scope:     resolver.scope,
+	}
+
+	// PrecacheRequests drops opaque entries if opts.OpaquePolicy is "skip"
+
+	precached := make(map[string]bool)
+
+	for _, r := range PrecacheRequests(to_cache, resolver.Origin(), opts) {
+
+		a := first[r.URL]
+		a.CrossOrigin = r.CrossOrigin
+
+		inventory.Assets = append(inventory.Assets, a)
+		precached[a.URL] = true
+	}
+
+	// fallbacks that were filtered out of the cache list would never be
+	// found in the cache so they are dropped
+
+	for dest, a := range fallbacks {
+
+		if precached[a.URL] {
+			inventory.Fallbacks[dest] = a.URL
+		}
+	}
+
+	inventory.setRevisions()
+
+	return inventory, nil
+}
+
+// inventoryDocument returns the (resolved) assets derived from the elements in
+// doc, following linked stylesheets, manifests, modules and frames as
+// configured by opts. lines maps elements to their line number in the source
+// document; it may be nil. depth is the number of frames doc is nested in.
+func inventoryDocument(doc *html.Node, lines map[*html.Node]int, resolver *Resolver, opts *ServiceWorkerOptions, seen map[string]bool, depth int) ([]*Asset, error) {
+
+	to_cache := make([]*Asset, 0)
+
+	source := ""
+
+	if resolver.location != nil {
+		source = resolver.location.String()
+	}
+
+	add := func(n *html.Node, ref *Reference) {
+
+		resolved, ok := resolver.Resolve(ref.URI)
+
+		if !ok {
+			return
+		}
+
+		kind := ref.Kind
+
+		if kind == "" {
+
+			switch ref.Follow {
+			case FollowStylesheet:
+				kind = KindStyle
+			case FollowModule:
+				kind = KindScript
+			case FollowDocument:
+				kind = KindDocument
+			default:
+				kind = InferKind(ref.Element, ref.Attribute, resolved)
+			}
+		}
+
+		a := &Asset{
+			URL:       resolved,
+			Value:     ref.URI,
+			Element:   ref.Element,
+			Attribute: ref.Attribute,
+			Kind:      kind,
+			Source:    source,
+			Line:      lines[n],
+		}
+
+		to_cache = append(to_cache, a)
 	}
 
 	out := ioutil.Discard
 
+	var walk_err error
+
 	var callback func(node *html.Node, writer io.Writer)
 
 	callback = func(n *html.Node, w io.Writer) {
 
+		if walk_err != nil {
+			return
+		}
+
 		if n.Type == html.ElementNode {
 
-			switch n.Data {
+			for _, ex := range opts.Extractors {
+
+				refs, err := ex.Extract(n, opts)
 
-			case "img":
+				if err != nil {
+					walk_err = err
+					return
+				}
+
+				for _, ref := range refs {
+
+					if ref.Follow == FollowDocument {
+
+						if opts.FollowFrames && depth < opts.FrameDepth {
+
+							frame_refs, ok := followDocument(resolver.Base(), ref.URI, resolver, opts, depth+1, seen)
 
-				for _, attr := range n.Attr {
+							if ok {
+								add(n, ref)
+								to_cache = append(to_cache, frame_refs...)
+							}
+						}
 
-					if attr.Key == "src" {
-						to_cache = append(to_cache, attr.Val)
-						break
+						continue
 					}
-				}
 
-			case "link":
+					add(n, ref)
+
+					switch ref.Follow {
+					case FollowStylesheet:
 
-				link := attrs2map(n.Attr...)
+						if opts.FollowStylesheets {
+							to_cache = append(to_cache, followStylesheet(resolver.Base(), ref.URI, resolver, opts, 1, seen)...)
+						}
 
-				rel, rel_ok := link["rel"]
-				href, href_ok := link["href"]
+					case FollowManifest:
 
-				if rel_ok && href_ok && rel == "stylesheet" {
-					to_cache = append(to_cache, href)
+						if opts.FollowManifests {
+							to_cache = append(to_cache, followManifest(resolver.Base(), ref.URI, resolver, opts, seen)...)
+						}
+
+					case FollowModule:
+
+						if opts.FollowModules {
+							to_cache = append(to_cache, followModule(resolver.Base(), ref.URI, resolver, opts, 1, seen)...)
+						}
+					}
 				}
+			}
 
-			case "script":
+			switch n.Data {
 
-				script := attrs2map(n.Attr...)
+			case "noscript":
 
-				script_type, script_type_ok := script["type"]
-				src, src_ok := script["src"]
+				// the contents of <noscript> elements are parsed as raw text
+				// so we need to parse them again as HTML
 
-				if script_type_ok && src_ok && script_type == "text/javascript" {
-					to_cache = append(to_cache, src)
-				}
+				if opts.Noscript {
 
-			case "source":
+					text, fragment := noscriptFragment(n)
 
-				// <picture> uses <source srcset="...">
-				// <video> uses <source src="...">
+					// line numbers in the fragment are relative to the
+					// start of the <noscript> element's contents
 
-				source := attrs2map(n.Attr...)
+					if lines[n] > 0 {
 
-				srcset, srcset_ok := source["srcset"]
+						fragment_lines := sourceLines([]byte(text), fragment...)
 
-				if srcset_ok {
-					to_cache = append(to_cache, srcset)
+						for c, line := range offsetLines(fragment_lines, lines[n]-1) {
+							lines[c] = line
+						}
+					}
+
+					for _, c := range fragment {
+						callback(c, out)
+					}
 				}
 
-				src, src_ok := source["src"]
+				return
+
+			case "template":
 
-				if src_ok {
-					to_cache = append(to_cache, src)
+				if !opts.Templates {
+					return
+				}
+
+			case "iframe":
+
+				srcdoc, ok := attrs2map(n.Attr...)["srcdoc"]
+
+				if ok && opts.FollowFrames && depth < opts.FrameDepth {
+					to_cache = append(to_cache, followSrcdoc(srcdoc, lines[n], resolver, opts, depth+1, seen)...)
 				}
 
 			default:
@@ -351,14 +909,39 @@ func CacheList(doc *html.Node, opts *ServiceWorkerOptions) ([]string, error) {
 
 	callback(doc, out)
 
-	for idx, uri := range to_cache {
+	if walk_err != nil {
+		return nil, walk_err
+	}
+
+	return to_cache, nil
+}
+
+// noscriptFragment returns the contents of the <noscript> element n and the
+// nodes it parses to.
+func noscriptFragment(n *html.Node) (string, []*html.Node) {
 
-		if !strings.HasPrefix(uri, "/") && !strings.HasPrefix(uri, "http") {
-			to_cache[idx] = fmt.Sprintf("./%s", uri)
+	var text strings.Builder
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+		if c.Type == html.TextNode {
+			text.WriteString(c.Data)
 		}
 	}
 
-	return to_cache, nil
+	context := &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: atom.Body,
+		Data:     "body",
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(text.String()), context)
+
+	if err != nil {
+		return text.String(), []*html.Node{}
+	}
+
+	return text.String(), nodes
 }
 
 func attrs2map(attrs ...html.Attribute) map[string]string {
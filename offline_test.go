@@ -0,0 +1,82 @@
+package offline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestServiceWorkerCachePrefix(t *testing.T) {
+
+	re_prefix := regexp.MustCompile(`var CACHE_PREFIX = '([^']*)';`)
+	re_cache := regexp.MustCompile(`var CACHE = '([^']*)';`)
+
+	tests := []struct {
+		location string
+		version  bool
+	}{
+		{"file:///site/a/index.html", true},
+		{"file:///site/b/index.html", true},
+		{"https://example.com/a/index.html", true},
+		{"https://example.com/b/index.html", false},
+	}
+
+	prefixes := make(map[string]string)
+
+	for _, test := range tests {
+
+		location, err := url.Parse(test.location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts := DefaultServiceWorkerOptions()
+		opts.VersionCache = test.version
+
+		var sw bytes.Buffer
+
+		err = AddServiceWorkerWithLocation(strings.NewReader("<html><head></head><body></body></html>"), location, ioutil.Discard, &sw, opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := re_prefix.FindStringSubmatch(sw.String())
+
+		if m == nil {
+			t.Fatalf("Service worker for %s is missing CACHE_PREFIX", test.location)
+		}
+
+		prefix := m[1]
+
+		if !strings.HasPrefix(prefix, opts.CacheName+"-") {
+			t.Fatalf("Expected cache prefix for %s to start with '%s-', got '%s'", test.location, opts.CacheName, prefix)
+		}
+
+		other, ok := prefixes[prefix]
+
+		if ok {
+			t.Fatalf("Service workers for %s and %s have the same cache prefix '%s'", other, test.location, prefix)
+		}
+
+		prefixes[prefix] = test.location
+
+		m = re_cache.FindStringSubmatch(sw.String())
+
+		if m == nil {
+			t.Fatalf("Service worker for %s is missing CACHE", test.location)
+		}
+
+		if test.version && !strings.HasPrefix(m[1], prefix+"-") {
+			t.Fatalf("Expected cache name for %s to start with '%s-', got '%s'", test.location, prefix, m[1])
+		}
+
+		if !test.version && m[1] != prefix {
+			t.Fatalf("Expected cache name for %s to be '%s', got '%s'", test.location, prefix, m[1])
+		}
+	}
+}
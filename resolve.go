@@ -0,0 +1,310 @@
+package offline
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Resolver resolves the URIs found in a document (and the resources it links
+// to) in to entries for the service worker's cache list. Relative URIs are
+// resolved against the document's location, or its <base href> element, and
+// then written either relative to the service worker's scope or as absolute
+// URLs. URIs with schemes that a service worker can not fetch (data:, blob:,
+// mailto: and so on) are dropped.
+type Resolver struct {
+	location *url.URL
+	base     *url.URL
+	scope    *url.URL
+	absolute bool
+}
+
+// NewResolver returns a new Resolver for the document at location, which may
+// be nil. Relative cache entries are written relative to opts.Scope or, if it
+// is empty, the directory of opts.ServiceWorkerURL.
+func NewResolver(location *url.URL, opts *ServiceWorkerOptions) (*Resolver, error) {
+
+	r := &Resolver{
+		location: location,
+		base:     location,
+		absolute: opts.AbsoluteURLs,
+	}
+
+	if location == nil {
+		return r, nil
+	}
+
+	scope := opts.Scope
+
+	if scope == "" {
+		scope = opts.ServiceWorkerURL
+	}
+
+	scope_url, err := url.Parse(scope)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scope_url = location.ResolveReference(scope_url)
+
+	if !strings.HasSuffix(scope_url.Path, "/") {
+		scope_url.Path = path.Dir(scope_url.Path) + "/"
+	}
+
+	scope_url.RawQuery = ""
+	scope_url.Fragment = ""
+
+	r.scope = scope_url
+	return r, nil
+}
+
+// SetBaseFromDocument updates the resolver's base URL using the first <base>
+// element with an href attribute in doc, if present.
+func (r *Resolver) SetBaseFromDocument(doc *html.Node) {
+
+	var find func(n *html.Node) (string, bool)
+
+	find = func(n *html.Node) (string, bool) {
+
+		if n.Type == html.ElementNode && n.Data == "base" {
+
+			href, ok := attrs2map(n.Attr...)["href"]
+
+			if ok {
+				return href, true
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+			href, ok := find(c)
+
+			if ok {
+				return href, true
+			}
+		}
+
+		return "", false
+	}
+
+	href, ok := find(doc)
+
+	if !ok {
+		return
+	}
+
+	base_url, err := url.Parse(strings.TrimSpace(href))
+
+	if err != nil {
+		return
+	}
+
+	// a root-relative <base href> in a local file can't be mapped back on to
+	// the filesystem so it is kept as-is and relative URIs resolved against it
+	// will also be root-relative
+
+	if r.location != nil && !(r.location.Scheme == "file" && base_url.Scheme == "" && strings.HasPrefix(base_url.Path, "/")) {
+		base_url = r.location.ResolveReference(base_url)
+	}
+
+	if !base_url.IsAbs() && !strings.HasPrefix(base_url.Path, "/") {
+		return
+	}
+
+	r.base = base_url
+}
+
+// WithLocation returns a copy of the resolver for the (nested) document at
+// location. Cache entries are still written relative to the original scope.
+func (r *Resolver) WithLocation(location *url.URL) *Resolver {
+
+	r2 := *r
+	r2.location = location
+	r2.base = location
+
+	return &r2
+}
+
+// IsSameOrigin returns true if u has the same origin as the service worker's
+// scope.
+func (r *Resolver) IsSameOrigin(u *url.URL) bool {
+
+	if r.scope == nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Scheme, r.scope.Scheme) && strings.EqualFold(u.Host, r.scope.Host)
+}
+
+// IsLocal returns true if the document the resolver was created for is a local
+// file.
+func (r *Resolver) IsLocal() bool {
+	return r.scope != nil && strings.EqualFold(r.scope.Scheme, "file")
+}
+
+// Origin returns the origin (scheme and host) of the service worker's scope or
+// an empty string if it is unknown or a local file.
+func (r *Resolver) Origin() string {
+
+	if r.scope == nil || r.scope.Scheme == "file" {
+		return ""
+	}
+
+	return strings.ToLower(r.scope.Scheme + "://" + r.scope.Host)
+}
+
+// Base returns the URL that relative URIs in the document are resolved
+// against. It may be nil.
+func (r *Resolver) Base() *url.URL {
+	return r.base
+}
+
+// ScopeURI returns the cache list entry for the service worker's scope.
+func (r *Resolver) ScopeURI() string {
+
+	if r.absolute && r.scope != nil && r.scope.Scheme != "file" {
+		return r.scope.String()
+	}
+
+	return "./"
+}
+
+// Resolve resolves uri against the document's base URL. It returns false if
+// uri is empty, invalid or not something a service worker can fetch.
+func (r *Resolver) Resolve(uri string) (string, bool) {
+	return r.ResolveReference(r.base, uri)
+}
+
+// ResolveURL resolves uri against base returning an absolute URL. It returns
+// false if base is nil or uri is not something a service worker can fetch.
+func (r *Resolver) ResolveURL(base *url.URL, uri string) (*url.URL, bool) {
+
+	if base == nil {
+		return nil, false
+	}
+
+	u, err := url.Parse(strings.TrimSpace(uri))
+
+	if err != nil || !isFetchableScheme(u.Scheme) {
+		return nil, false
+	}
+
+	u = base.ResolveReference(u)
+
+	if !u.IsAbs() {
+		return nil, false
+	}
+
+	return u, true
+}
+
+// ResolveReference resolves uri against base, which may be nil. It returns
+// false if uri is empty, invalid or not something a service worker can fetch.
+func (r *Resolver) ResolveReference(base *url.URL, uri string) (string, bool) {
+
+	uri = strings.TrimSpace(uri)
+
+	// fragment-only references (for example <use href="#icon">) point back
+	// to the document itself
+
+	if uri == "" || strings.HasPrefix(uri, "#") {
+		return "", false
+	}
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return "", false
+	}
+
+	if !isFetchableScheme(u.Scheme) {
+		return "", false
+	}
+
+	if base == nil {
+
+		// protocol-relative URIs are left for the browser to resolve
+		return uri, true
+	}
+
+	// root-relative references in local files can't be mapped back on to
+	// the filesystem and protocol-relative ones should use the scheme the
+	// service worker is served from so pass them along as-is
+
+	if base.Scheme == "file" && u.Scheme == "" && strings.HasPrefix(uri, "/") {
+		return uri, true
+	}
+
+	return r.Format(base.ResolveReference(u)), true
+}
+
+// Format returns the cache list entry for the absolute URL u.
+func (r *Resolver) Format(u *url.URL) string {
+
+	if u.Fragment != "" {
+		u2 := *u
+		u2.Fragment = ""
+		u2.RawFragment = ""
+		u = &u2
+	}
+
+	if !r.IsSameOrigin(u) {
+		return u.String()
+	}
+
+	if r.absolute && u.Scheme != "file" {
+		return u.String()
+	}
+
+	if u.Scheme == "file" {
+
+		rel, err := filepath.Rel(filepath.FromSlash(r.scope.Path), filepath.FromSlash(u.Path))
+
+		if err != nil {
+			return u.String()
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasSuffix(u.Path, "/") && !strings.HasSuffix(rel, "/") {
+			rel = fmt.Sprintf("%s/", rel)
+		}
+
+		if rel != "./" && !strings.HasPrefix(rel, "../") {
+			rel = fmt.Sprintf("./%s", rel)
+		}
+
+		if u.RawQuery != "" {
+			rel = fmt.Sprintf("%s?%s", rel, u.RawQuery)
+		}
+
+		return rel
+	}
+
+	if strings.HasPrefix(u.Path, r.scope.Path) {
+
+		rel := fmt.Sprintf("./%s", strings.TrimPrefix(u.EscapedPath(), r.scope.EscapedPath()))
+
+		if u.RawQuery != "" {
+			rel = fmt.Sprintf("%s?%s", rel, u.RawQuery)
+		}
+
+		return rel
+	}
+
+	return u.RequestURI()
+}
+
+func isFetchableScheme(scheme string) bool {
+
+	switch strings.ToLower(scheme) {
+	case "", "http", "https":
+		return true
+	default:
+		return false
+	}
+}
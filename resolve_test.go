@@ -0,0 +1,122 @@
+package offline
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResolverFormat(t *testing.T) {
+
+	tests := []struct {
+		location string
+		scope    string
+		absolute bool
+		uri      string
+		expected string
+	}{
+		{"https://example.com/a/index.html", "", false, "https://example.com/a/img/x.png", "./img/x.png"},
+		{"https://example.com/a/index.html", "", false, "https://example.com/a/x.png?v=1", "./x.png?v=1"},
+		{"https://example.com/a/index.html", "", false, "https://example.com/a/x.png#top", "./x.png"},
+		{"https://example.com/a/index.html", "", false, "https://example.com/b/x.png", "/b/x.png"},
+		{"https://example.com/a/index.html", "", false, "https://cdn.example.com/x.png", "https://cdn.example.com/x.png"},
+		{"https://example.com/a/index.html", "", false, "http://example.com/a/x.png", "http://example.com/a/x.png"},
+		{"https://example.com/a/index.html", "", true, "https://example.com/a/x.png", "https://example.com/a/x.png"},
+		{"https://example.com/a/index.html", "/", false, "https://example.com/a/x.png", "./a/x.png"},
+		{"https://example.com/a/index.html", "/a/b/sw.js", false, "https://example.com/a/x.png", "/a/x.png"},
+		{"file:///site/page/index.html", "", false, "file:///site/page/img/x.png", "./img/x.png"},
+		{"file:///site/page/index.html", "", false, "file:///site/x.png", "../x.png"},
+		{"file:///site/page/index.html", "", false, "file:///site/page/dir/", "./dir/"},
+		{"file:///site/page/index.html", "", false, "file:///site/page/x.png?v=2", "./x.png?v=2"},
+		{"file:///site/page/index.html", "", true, "file:///site/page/x.png", "./x.png"},
+	}
+
+	for _, test := range tests {
+
+		opts := DefaultServiceWorkerOptions()
+		opts.AbsoluteURLs = test.absolute
+
+		if test.scope != "" {
+			opts.Scope = test.scope
+		}
+
+		location, err := url.Parse(test.location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolver, err := NewResolver(location, opts)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(test.uri)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		formatted := resolver.Format(u)
+
+		if formatted != test.expected {
+			t.Fatalf("Expected %s (relative to %s, scope '%s') to be formatted as '%s', got '%s'", test.uri, test.location, test.scope, test.expected, formatted)
+		}
+	}
+}
+
+func TestResolverResolve(t *testing.T) {
+
+	tests := []struct {
+		location string
+		doc      string
+		uri      string
+		expected string
+		ok       bool
+	}{
+		{"https://example.com/a/index.html", "", "x.png", "./x.png", true},
+		{"https://example.com/a/index.html", "", "../b/x.png", "/b/x.png", true},
+		{"https://example.com/a/index.html", "", "//cdn.example.com/x.png", "https://cdn.example.com/x.png", true},
+		{"https://example.com/a/index.html", `<base href="/c/">`, "x.png", "/c/x.png", true},
+		{"https://example.com/a/index.html", `<base href="https://cdn.example.com/">`, "x.png", "https://cdn.example.com/x.png", true},
+		{"https://example.com/a/index.html", "", "#icon", "", false},
+		{"https://example.com/a/index.html", "", "data:image/png;base64,AAAA", "", false},
+		{"https://example.com/a/index.html", "", "mailto:a@example.com", "", false},
+		{"file:///site/page/index.html", "", "/x.png", "/x.png", true},
+		{"file:///site/page/index.html", "", "../x.png", "../x.png", true},
+	}
+
+	for _, test := range tests {
+
+		location, err := url.Parse(test.location)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolver, err := NewResolver(location, DefaultServiceWorkerOptions())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if test.doc != "" {
+
+			doc, err := html.Parse(strings.NewReader(test.doc))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resolver.SetBaseFromDocument(doc)
+		}
+
+		resolved, ok := resolver.Resolve(test.uri)
+
+		if ok != test.ok || resolved != test.expected {
+			t.Fatalf("Expected %s (relative to %s) to resolve to '%s' (%t), got '%s' (%t)", test.uri, test.location, test.expected, test.ok, resolved, ok)
+		}
+	}
+}
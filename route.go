@@ -0,0 +1,210 @@
+package offline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Route is a rule for handling the requests a service worker receives. Routes
+// are evaluated in order and the first one whose Pattern and Kind both match
+// a request is used. Requests which don't match any route are handled using
+// the ServiceWorkerOptions Strategy and NetworkTimeout properties.
+type Route struct {
+	// Pattern is a glob pattern ("*" matches any sequence of characters and
+	// "?" any single character). Patterns beginning with "/" are matched
+	// against the path and query of same-origin requests, all others against
+	// the absolute URL. It may be empty in which case every URL matches.
+	Pattern string
+	// Kind is the kind of asset being requested (see InferKind). It may be
+	// empty in which case every kind matches.
+	Kind     string
+	Strategy string
+	// CacheName is the name of the cache that responses are stored in. It may
+	// be empty in which case responses are stored in the runtime cache, if
+	// runtime caching is enabled, or not at all. Responses for assets in the
+	// cache list are always stored in the service worker's cache.
+	CacheName    string
+	IgnoreSearch bool
+	IgnoreVary   bool
+	// NetworkTimeout is the number of milliseconds to wait for a network
+	// response using the network-first strategy. Zero means use the service
+	// worker's NetworkTimeout.
+	NetworkTimeout int
+	// MaxEntries and MaxAge (in seconds) limit the size of the route's cache.
+	// They are ignored unless CacheName is set. Zero means no limit.
+	MaxEntries int
+	MaxAge     int
+}
+
+func NewRoute(pattern string, kind string, strategy string) (*Route, error) {
+
+	if kind != "" && !IsValidKind(kind) {
+		return nil, fmt.Errorf("Invalid route kind '%s'", kind)
+	}
+
+	if !IsValidStrategy(strategy) {
+		return nil, fmt.Errorf("Invalid route strategy '%s'", strategy)
+	}
+
+	r := &Route{
+		Pattern:  pattern,
+		Kind:     kind,
+		Strategy: strategy,
+	}
+
+	return r, nil
+}
+
+// ParseRoute parses a string of space-separated key=value pairs in to a Route,
+// for example:
+//
+//	pattern=/api/* strategy=network-only
+//	kind=image strategy=cache-first cache=images
+//	kind=document strategy=network-first timeout=2000 ignore-search=true
+//	kind=image strategy=stale-while-revalidate cache=images max-entries=50
+//
+// Valid keys are: pattern, kind, strategy, cache, ignore-search, ignore-vary,
+// timeout, max-entries and max-age.
+func ParseRoute(str string) (*Route, error) {
+
+	var pattern string
+	var kind string
+	var strategy string
+	var cache_name string
+	var ignore_search bool
+	var ignore_vary bool
+	var timeout int
+	var max_entries int
+	var max_age int
+
+	for _, pair := range strings.Fields(str) {
+
+		kv := strings.SplitN(pair, "=", 2)
+
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid route '%s'", str)
+		}
+
+		var err error
+
+		switch kv[0] {
+		case "pattern":
+			pattern = kv[1]
+		case "kind":
+			kind = kv[1]
+		case "strategy":
+			strategy = kv[1]
+		case "cache":
+			cache_name = kv[1]
+		case "ignore-search":
+			ignore_search, err = strconv.ParseBool(kv[1])
+		case "ignore-vary":
+			ignore_vary, err = strconv.ParseBool(kv[1])
+		case "timeout":
+
+			timeout, err = strconv.Atoi(kv[1])
+
+			if err == nil && timeout < 0 {
+				err = fmt.Errorf("Timeout must be zero or greater")
+			}
+
+		case "max-entries":
+
+			max_entries, err = strconv.Atoi(kv[1])
+
+			if err == nil && max_entries < 0 {
+				err = fmt.Errorf("Max entries must be zero or greater")
+			}
+
+		case "max-age":
+
+			max_age, err = strconv.Atoi(kv[1])
+
+			if err == nil && max_age < 0 {
+				err = fmt.Errorf("Max age must be zero or greater")
+			}
+
+		default:
+			return nil, fmt.Errorf("Invalid route key '%s'", kv[0])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid route value for '%s', %v", kv[0], err)
+		}
+	}
+
+	r, err := NewRoute(pattern, kind, strategy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.CacheName = cache_name
+	r.IgnoreSearch = ignore_search
+	r.IgnoreVary = ignore_vary
+	r.NetworkTimeout = timeout
+	r.MaxEntries = max_entries
+	r.MaxAge = max_age
+
+	return r, nil
+}
+
+// IsPathPattern returns true if the route's pattern is matched against the
+// path and query of same-origin requests.
+func (r *Route) IsPathPattern() bool {
+	return strings.HasPrefix(r.Pattern, "/")
+}
+
+// PatternRegexp returns the route's pattern as a regular expression which is
+// valid in both Go and JavaScript. It returns an empty string if the route
+// doesn't have a pattern.
+func (r *Route) PatternRegexp() string {
+
+	if r.Pattern == "" {
+		return ""
+	}
+
+	return globRegexp(r.Pattern)
+}
+
+func (r *Route) String() string {
+
+	parts := make([]string, 0)
+
+	if r.Pattern != "" {
+		parts = append(parts, fmt.Sprintf("pattern=%s", r.Pattern))
+	}
+
+	if r.Kind != "" {
+		parts = append(parts, fmt.Sprintf("kind=%s", r.Kind))
+	}
+
+	parts = append(parts, fmt.Sprintf("strategy=%s", r.Strategy))
+
+	if r.CacheName != "" {
+		parts = append(parts, fmt.Sprintf("cache=%s", r.CacheName))
+	}
+
+	if r.IgnoreSearch {
+		parts = append(parts, "ignore-search=true")
+	}
+
+	if r.IgnoreVary {
+		parts = append(parts, "ignore-vary=true")
+	}
+
+	if r.NetworkTimeout != 0 {
+		parts = append(parts, fmt.Sprintf("timeout=%d", r.NetworkTimeout))
+	}
+
+	if r.MaxEntries != 0 {
+		parts = append(parts, fmt.Sprintf("max-entries=%d", r.MaxEntries))
+	}
+
+	if r.MaxAge != 0 {
+		parts = append(parts, fmt.Sprintf("max-age=%d", r.MaxAge))
+	}
+
+	return strings.Join(parts, " ")
+}
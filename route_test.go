@@ -0,0 +1,116 @@
+package offline
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+
+	tests := []struct {
+		str      string
+		expected *Route
+	}{
+		{"pattern=/api/* strategy=network-only", &Route{Pattern: "/api/*", Strategy: StrategyNetworkOnly}},
+		{"kind=image strategy=cache-first cache=images", &Route{Kind: KindImage, Strategy: StrategyCacheFirst, CacheName: "images"}},
+		{"kind=document strategy=network-first timeout=2000 ignore-search=true", &Route{Kind: KindDocument, Strategy: StrategyNetworkFirst, NetworkTimeout: 2000, IgnoreSearch: true}},
+		{"  kind=image   strategy=stale-while-revalidate cache=images max-entries=50 max-age=3600 ignore-vary=1 ", &Route{Kind: KindImage, Strategy: StrategyStaleWhileRevalidate, CacheName: "images", MaxEntries: 50, MaxAge: 3600, IgnoreVary: true}},
+		{"pattern=https://cdn.example.com/*?v=* strategy=cache-only", &Route{Pattern: "https://cdn.example.com/*?v=*", Strategy: StrategyCacheOnly}},
+		{"", nil},
+		{"kind=image", nil},
+		{"strategy=fastest", nil},
+		{"kind=video strategy=cache-first", nil},
+		{"strategy=cache-first colour=red", nil},
+		{"strategy=cache-first cache", nil},
+		{"strategy=network-first timeout=-1", nil},
+		{"strategy=network-first timeout=soon", nil},
+		{"strategy=cache-first max-entries=-5", nil},
+		{"strategy=cache-first max-age=-5", nil},
+		{"strategy=cache-first ignore-search=maybe", nil},
+	}
+
+	for _, test := range tests {
+
+		r, err := ParseRoute(test.str)
+
+		if test.expected == nil {
+
+			if err == nil {
+				t.Fatalf("Expected '%s' to fail, got '%s'", test.str, r.String())
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", test.str, err)
+		}
+
+		if *r != *test.expected {
+			t.Fatalf("Expected '%s' to parse as %+v, got %+v", test.str, test.expected, r)
+		}
+
+		// String returns the canonical form of the route
+
+		r2, err := ParseRoute(r.String())
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", r.String(), err)
+		}
+
+		if *r2 != *r {
+			t.Fatalf("Expected '%s' to parse as %+v, got %+v", r.String(), r, r2)
+		}
+	}
+}
+
+func TestRoutePatternRegexp(t *testing.T) {
+
+	tests := []struct {
+		pattern  string
+		expected string
+		matches  []string
+		misses   []string
+	}{
+		{"", "", nil, nil},
+		{"/api/*", `^/api/.*$`, []string{"/api/", "/api/v1/items?page=2"}, []string{"/apix", "/static/api/x"}},
+		{"/img/??.png", `^/img/..\.png$`, []string{"/img/ab.png"}, []string{"/img/abc.png", "/img/abXpng"}},
+		{"https://cdn.example.com/*", `^https://cdn\.example\.com/.*$`, []string{"https://cdn.example.com/a.js"}, []string{"https://cdnXexample.com/a.js", "http://cdn.example.com/a.js"}},
+		{"/search?q=(a|b)+[c]{2}^$\\", `^/search.q=\(a\|b\)\+\[c\]\{2\}\^\$\\$`, []string{"/search?q=(a|b)+[c]{2}^$\\", "/searchXq=(a|b)+[c]{2}^$\\"}, []string{"/search?q=a", "/search?q=(a|b)+[c]{2}^$"}},
+	}
+
+	for _, test := range tests {
+
+		r := &Route{Pattern: test.pattern, Strategy: StrategyNetworkFirst}
+
+		str_re := r.PatternRegexp()
+
+		if str_re != test.expected {
+			t.Fatalf("Expected pattern '%s' to be '%s', got '%s'", test.pattern, test.expected, str_re)
+		}
+
+		if str_re == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(str_re)
+
+		if err != nil {
+			t.Fatalf("Failed to compile pattern '%s', %v", test.pattern, err)
+		}
+
+		for _, str := range test.matches {
+
+			if !re.MatchString(str) {
+				t.Fatalf("Expected pattern '%s' to match '%s'", test.pattern, str)
+			}
+		}
+
+		for _, str := range test.misses {
+
+			if re.MatchString(str) {
+				t.Fatalf("Expected pattern '%s' not to match '%s'", test.pattern, str)
+			}
+		}
+	}
+}
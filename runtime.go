@@ -0,0 +1,45 @@
+package offline
+
+// CacheExpiration defines the limits for a runtime cache. MaxAge is in seconds.
+// Zero means no limit.
+type CacheExpiration struct {
+	MaxEntries int
+	MaxAge     int
+}
+
+// cacheExpirations returns the limits for each runtime cache, keyed by cache
+// name, defined by opts.
+func cacheExpirations(opts *ServiceWorkerOptions) map[string]*CacheExpiration {
+
+	expirations := make(map[string]*CacheExpiration)
+
+	if opts.RuntimeCaching && (opts.RuntimeMaxEntries > 0 || opts.RuntimeMaxAge > 0) {
+
+		expirations[opts.RuntimeCacheName] = &CacheExpiration{
+			MaxEntries: opts.RuntimeMaxEntries,
+			MaxAge:     opts.RuntimeMaxAge,
+		}
+	}
+
+	for _, r := range opts.Routes {
+
+		if r.CacheName == "" || (r.MaxEntries == 0 && r.MaxAge == 0) {
+			continue
+		}
+
+		// the first route to define limits for a cache wins
+
+		_, ok := expirations[r.CacheName]
+
+		if ok {
+			continue
+		}
+
+		expirations[r.CacheName] = &CacheExpiration{
+			MaxEntries: r.MaxEntries,
+			MaxAge:     r.MaxAge,
+		}
+	}
+
+	return expirations
+}
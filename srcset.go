@@ -0,0 +1,437 @@
+package offline
+
+// https://html.spec.whatwg.org/multipage/images.html#parsing-a-srcset-attribute
+// https://html.spec.whatwg.org/multipage/images.html#parsing-a-sizes-attribute
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	SrcsetAll            = "all"
+	SrcsetLargest        = "largest"
+	SrcsetSmallest       = "smallest"
+	SrcsetNearestWidth   = "nearest-width"
+	SrcsetNearestDensity = "nearest-density"
+)
+
+type SrcsetCandidate struct {
+	URL     string
+	Width   int
+	Density float64
+}
+
+type SourceSize struct {
+	Media  string
+	Length string
+}
+
+func IsValidSrcsetPolicy(policy string) bool {
+
+	switch policy {
+	case SrcsetAll, SrcsetLargest, SrcsetSmallest, SrcsetNearestWidth, SrcsetNearestDensity:
+		return true
+	default:
+		return false
+	}
+}
+
+func ParseSrcset(str string) []*SrcsetCandidate {
+
+	candidates := make([]*SrcsetCandidate, 0)
+
+	input := []rune(str)
+	pos := 0
+
+	for {
+
+		for pos < len(input) && (isSrcsetSpace(input[pos]) || input[pos] == ',') {
+			pos += 1
+		}
+
+		if pos >= len(input) {
+			break
+		}
+
+		start := pos
+
+		for pos < len(input) && !isSrcsetSpace(input[pos]) {
+			pos += 1
+		}
+
+		url := string(input[start:pos])
+		descriptors := make([]string, 0)
+
+		if strings.HasSuffix(url, ",") {
+
+			url = strings.TrimRight(url, ",")
+
+		} else {
+
+			var current strings.Builder
+			in_parens := false
+
+		descriptors:
+			for ; pos < len(input); pos++ {
+
+				c := input[pos]
+
+				switch {
+				case in_parens:
+
+					current.WriteRune(c)
+
+					if c == ')' {
+						in_parens = false
+					}
+
+				case c == '(':
+
+					current.WriteRune(c)
+					in_parens = true
+
+				case c == ',':
+
+					pos += 1
+					break descriptors
+
+				case isSrcsetSpace(c):
+
+					if current.Len() > 0 {
+						descriptors = append(descriptors, current.String())
+						current.Reset()
+					}
+
+				default:
+					current.WriteRune(c)
+				}
+			}
+
+			if current.Len() > 0 {
+				descriptors = append(descriptors, current.String())
+			}
+		}
+
+		if url == "" {
+			continue
+		}
+
+		candidate, ok := parseSrcsetDescriptors(url, descriptors)
+
+		if ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates
+}
+
+func parseSrcsetDescriptors(url string, descriptors []string) (*SrcsetCandidate, bool) {
+
+	candidate := &SrcsetCandidate{
+		URL: url,
+	}
+
+	for _, d := range descriptors {
+
+		if len(d) < 2 {
+			return nil, false
+		}
+
+		value := d[:len(d)-1]
+
+		switch d[len(d)-1] {
+
+		case 'w':
+
+			if candidate.Width != 0 || candidate.Density != 0 {
+				return nil, false
+			}
+
+			w, err := strconv.Atoi(value)
+
+			if err != nil || w <= 0 {
+				return nil, false
+			}
+
+			candidate.Width = w
+
+		case 'x':
+
+			if candidate.Width != 0 || candidate.Density != 0 {
+				return nil, false
+			}
+
+			x, err := strconv.ParseFloat(value, 64)
+
+			if err != nil || x < 0 {
+				return nil, false
+			}
+
+			candidate.Density = x
+
+		case 'h':
+			// future-compat height descriptor, ignored
+
+		default:
+			return nil, false
+		}
+	}
+
+	return candidate, true
+}
+
+func ParseSizes(str string) []*SourceSize {
+
+	sizes := make([]*SourceSize, 0)
+
+	for _, part := range splitOutsideParens(str, ',') {
+
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		idx := strings.LastIndexFunc(part, func(r rune) bool {
+			return isSrcsetSpace(r) || r == ')'
+		})
+
+		// a trailing calc(...) is the length, not the media condition
+
+		if strings.HasSuffix(part, ")") {
+
+			open := strings.LastIndex(part, "calc(")
+
+			if open == -1 {
+				continue
+			}
+
+			idx = open - 1
+		}
+
+		size := &SourceSize{
+			Length: strings.TrimSpace(part[idx+1:]),
+		}
+
+		if idx > 0 {
+			size.Media = strings.TrimSpace(part[:idx+1])
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	return sizes
+}
+
+// SlotWidth returns the width, in CSS pixels, that a sizes attribute evaluates
+// to for a given viewport width. Only simple min-width and max-width media
+// conditions are understood; anything else is treated as not matching.
+func SlotWidth(sizes []*SourceSize, viewport int) float64 {
+
+	for _, sz := range sizes {
+
+		if sz.Media != "" && !matchMediaCondition(sz.Media, viewport) {
+			continue
+		}
+
+		w, ok := parseCSSLength(sz.Length, viewport)
+
+		if ok {
+			return w
+		}
+	}
+
+	return float64(viewport)
+}
+
+func SelectSrcset(srcset string, sizes string, opts *ServiceWorkerOptions) []string {
+
+	return selectSrcsetCandidates(ParseSrcset(srcset), sizes, opts)
+}
+
+func selectSrcsetCandidates(candidates []*SrcsetCandidate, sizes string, opts *ServiceWorkerOptions) []string {
+
+	if len(candidates) == 0 {
+		return []string{}
+	}
+
+	policy := opts.SrcsetPolicy
+
+	if policy == "" || policy == SrcsetAll {
+
+		urls := make([]string, len(candidates))
+
+		for idx, c := range candidates {
+			urls[idx] = c.URL
+		}
+
+		return urls
+	}
+
+	viewport := opts.SrcsetViewportWidth
+
+	if viewport <= 0 {
+		viewport = 1280
+	}
+
+	slot := SlotWidth(ParseSizes(sizes), viewport)
+
+	if slot <= 0 {
+		slot = float64(viewport)
+	}
+
+	density := func(c *SrcsetCandidate) float64 {
+
+		switch {
+		case c.Width != 0:
+			return float64(c.Width) / slot
+		case c.Density != 0:
+			return c.Density
+		default:
+			return 1.0
+		}
+	}
+
+	// without an explicit target width the nearest-width policy aims for the
+	// width of the slot the image is displayed in
+
+	target_width := float64(opts.SrcsetTargetWidth)
+
+	if target_width <= 0 {
+		target_width = slot
+	}
+
+	best := candidates[0]
+	best_score := math.Inf(1)
+
+	for _, c := range candidates {
+
+		var score float64
+
+		switch policy {
+		case SrcsetLargest:
+			score = -density(c)
+		case SrcsetSmallest:
+			score = density(c)
+		case SrcsetNearestWidth:
+			score = math.Abs(density(c)*slot - target_width)
+		case SrcsetNearestDensity:
+			score = math.Abs(density(c) - opts.SrcsetTargetDensity)
+		}
+
+		if score < best_score {
+			best = c
+			best_score = score
+		}
+	}
+
+	return []string{best.URL}
+}
+
+func matchMediaCondition(media string, viewport int) bool {
+
+	media = strings.ToLower(strings.TrimSpace(media))
+
+	for _, cond := range strings.Split(media, " and ") {
+
+		cond = strings.TrimSpace(cond)
+
+		if !strings.HasPrefix(cond, "(") || !strings.HasSuffix(cond, ")") {
+			return false
+		}
+
+		cond = strings.Trim(cond, "()")
+		parts := strings.SplitN(cond, ":", 2)
+
+		if len(parts) != 2 {
+			return false
+		}
+
+		w, ok := parseCSSLength(strings.TrimSpace(parts[1]), viewport)
+
+		if !ok {
+			return false
+		}
+
+		switch strings.TrimSpace(parts[0]) {
+		case "min-width":
+
+			if float64(viewport) < w {
+				return false
+			}
+
+		case "max-width":
+
+			if float64(viewport) > w {
+				return false
+			}
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseCSSLength(length string, viewport int) (float64, bool) {
+
+	length = strings.ToLower(strings.TrimSpace(length))
+
+	units := map[string]float64{
+		"px":  1.0,
+		"vw":  float64(viewport) / 100.0,
+		"rem": 16.0,
+		"em":  16.0,
+	}
+
+	for _, u := range []string{"px", "vw", "rem", "em"} {
+
+		if !strings.HasSuffix(length, u) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSuffix(length, u), 64)
+
+		if err != nil || v < 0 {
+			return 0, false
+		}
+
+		return v * units[u], true
+	}
+
+	if length == "0" {
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func splitOutsideParens(str string, sep rune) []string {
+
+	parts := make([]string, 0)
+	depth := 0
+	start := 0
+
+	for idx, c := range str {
+
+		switch {
+		case c == '(':
+			depth += 1
+		case c == ')' && depth > 0:
+			depth -= 1
+		case c == sep && depth == 0:
+			parts = append(parts, str[start:idx])
+			start = idx + 1
+		}
+	}
+
+	return append(parts, str[start:])
+}
+
+func isSrcsetSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
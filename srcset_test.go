@@ -0,0 +1,138 @@
+package offline
+
+import (
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+
+	tests := []struct {
+		srcset   string
+		expected []SrcsetCandidate
+	}{
+		{"", []SrcsetCandidate{}},
+		{"a.jpg", []SrcsetCandidate{{URL: "a.jpg"}}},
+		{"a.jpg 1x, b.jpg 2x", []SrcsetCandidate{{URL: "a.jpg", Density: 1}, {URL: "b.jpg", Density: 2}}},
+		{"a.jpg 320w,b.jpg 640w", []SrcsetCandidate{{URL: "a.jpg", Width: 320}, {URL: "b.jpg", Width: 640}}},
+		{"  a.jpg   1.5x ,\n\tb.jpg 3x  ", []SrcsetCandidate{{URL: "a.jpg", Density: 1.5}, {URL: "b.jpg", Density: 3}}},
+		{"a.jpg, b.jpg 2x", []SrcsetCandidate{{URL: "a.jpg"}, {URL: "b.jpg", Density: 2}}},
+		{"a.jpg,b.jpg 2x", []SrcsetCandidate{{URL: "a.jpg,b.jpg", Density: 2}}},
+		{"img,w=100.jpg 100w", []SrcsetCandidate{{URL: "img,w=100.jpg", Width: 100}}},
+		{"a.jpg 100w 200h", []SrcsetCandidate{{URL: "a.jpg", Width: 100}}},
+		{"a.jpg 100w 2x, b.jpg 0w, c.jpg -1x, d.jpg 10q, e.jpg 2x", []SrcsetCandidate{{URL: "e.jpg", Density: 2}}},
+		{"a.jpg (foo, bar) 2x", []SrcsetCandidate{}},
+		{",,, a.jpg 1x,,", []SrcsetCandidate{{URL: "a.jpg", Density: 1}}},
+	}
+
+	for _, test := range tests {
+
+		candidates := ParseSrcset(test.srcset)
+
+		if len(candidates) != len(test.expected) {
+			t.Fatalf("Expected %d candidates for '%s', got %d", len(test.expected), test.srcset, len(candidates))
+		}
+
+		for idx, c := range candidates {
+
+			if *c != test.expected[idx] {
+				t.Fatalf("Expected candidate %d for '%s' to be %v, got %v", idx, test.srcset, test.expected[idx], *c)
+			}
+		}
+	}
+}
+
+func TestParseSizes(t *testing.T) {
+
+	tests := []struct {
+		sizes    string
+		expected []SourceSize
+	}{
+		{"", []SourceSize{}},
+		{"100vw", []SourceSize{{Length: "100vw"}}},
+		{"(max-width: 600px) 480px, 800px", []SourceSize{{Media: "(max-width: 600px)", Length: "480px"}, {Length: "800px"}}},
+		{"(min-width: 1200px) calc(33vw - 20px), 100vw", []SourceSize{{Media: "(min-width: 1200px)", Length: "calc(33vw - 20px)"}, {Length: "100vw"}}},
+		{"calc(100vw - 2em)", []SourceSize{{Length: "calc(100vw - 2em)"}}},
+		{"(min-width: 400px) and (max-width: 800px) 50vw , 100vw", []SourceSize{{Media: "(min-width: 400px) and (max-width: 800px)", Length: "50vw"}, {Length: "100vw"}}},
+	}
+
+	for _, test := range tests {
+
+		sizes := ParseSizes(test.sizes)
+
+		if len(sizes) != len(test.expected) {
+			t.Fatalf("Expected %d sizes for '%s', got %d", len(test.expected), test.sizes, len(sizes))
+		}
+
+		for idx, sz := range sizes {
+
+			if *sz != test.expected[idx] {
+				t.Fatalf("Expected size %d for '%s' to be %v, got %v", idx, test.sizes, test.expected[idx], *sz)
+			}
+		}
+	}
+}
+
+func TestSlotWidth(t *testing.T) {
+
+	tests := []struct {
+		sizes    string
+		viewport int
+		expected float64
+	}{
+		{"", 1280, 1280},
+		{"50vw", 1000, 500},
+		{"(max-width: 600px) 480px, 800px", 500, 480},
+		{"(max-width: 600px) 480px, 800px", 1280, 800},
+		{"(min-width: 1200px) 50vw, 100vw", 1280, 640},
+		// calc() lengths aren't evaluated so the next size is used
+		{"(min-width: 1200px) calc(50vw - 40px), 100vw", 1280, 1280},
+	}
+
+	for _, test := range tests {
+
+		w := SlotWidth(ParseSizes(test.sizes), test.viewport)
+
+		if w != test.expected {
+			t.Fatalf("Expected slot width for '%s' (viewport %d) to be %f, got %f", test.sizes, test.viewport, test.expected, w)
+		}
+	}
+}
+
+func TestSelectSrcset(t *testing.T) {
+
+	srcset := "s.jpg 320w, m.jpg 640w, l.jpg 1280w"
+
+	tests := []struct {
+		policy   string
+		sizes    string
+		width    int
+		expected []string
+	}{
+		{SrcsetAll, "", 0, []string{"s.jpg", "m.jpg", "l.jpg"}},
+		{SrcsetLargest, "", 0, []string{"l.jpg"}},
+		{SrcsetSmallest, "", 0, []string{"s.jpg"}},
+		{SrcsetNearestWidth, "", 700, []string{"m.jpg"}},
+		{SrcsetNearestWidth, "(max-width: 2000px) 600px", 0, []string{"m.jpg"}},
+		{SrcsetNearestWidth, "", 0, []string{"l.jpg"}},
+	}
+
+	for _, test := range tests {
+
+		opts := DefaultServiceWorkerOptions()
+		opts.SrcsetPolicy = test.policy
+		opts.SrcsetTargetWidth = test.width
+
+		urls := SelectSrcset(srcset, test.sizes, opts)
+
+		if len(urls) != len(test.expected) {
+			t.Fatalf("Expected %v for policy %s (sizes '%s', width %d), got %v", test.expected, test.policy, test.sizes, test.width, urls)
+		}
+
+		for idx, u := range urls {
+
+			if u != test.expected[idx] {
+				t.Fatalf("Expected %v for policy %s (sizes '%s', width %d), got %v", test.expected, test.policy, test.sizes, test.width, urls)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package offline
+
+// https://developer.chrome.com/docs/workbox/caching-strategies-overview/
+
+const (
+	StrategyCacheFirst           = "cache-first"
+	StrategyNetworkFirst         = "network-first"
+	StrategyStaleWhileRevalidate = "stale-while-revalidate"
+	StrategyCacheOnly            = "cache-only"
+	StrategyNetworkOnly          = "network-only"
+)
+
+// IsValidStrategy returns true if strategy is one of the caching strategies
+// the service worker implements.
+func IsValidStrategy(strategy string) bool {
+
+	switch strategy {
+	case StrategyCacheFirst, StrategyNetworkFirst, StrategyStaleWhileRevalidate, StrategyCacheOnly, StrategyNetworkOnly:
+		return true
+	default:
+		return false
+	}
+}
@@ -11,66 +11,907 @@ func init() {
 // this file was generated by robots on {{ .Date }}
 // https://github.com/sfomuseum/go-html-offline
 
-var CACHE = '{{ .CacheName }}';
+var CACHE = '{{ js .CacheName }}';
+var CACHE_PREFIX = '{{ js .CachePrefix }}';
+var CACHE_VERSION = '{{ js .CacheVersion }}';
+var LEGACY_CACHE = '{{ js .LegacyCacheName }}';
+var KEEP_VERSIONS = {{ .KeepVersions }};
+var SKIP_WAITING = {{ .SkipWaiting }};
+var CLIENTS_CLAIM = {{ .ClientsClaim }};
+var NAVIGATION_PRELOAD = {{ .NavigationPreload }};
+var PRECACHE_MODE = '{{ .PrecacheMode }}';
+var PRECACHE_RETRIES = {{ .PrecacheRetries }};
+var PRECACHE_RETRY_DELAY = {{ .PrecacheRetryDelay }};
+
+// the revisions of the precached items are stored alongside them, in the
+// cache, using this key
+
+var REVISIONS = './__precache-revisions__';
+var STRATEGY = '{{ .Strategy }}';
+var NETWORK_TIMEOUT = {{ .NetworkTimeout }};
+
+// responses for requests that aren't precached are stored in RUNTIME_CACHE
+// unless a route names a different cache
+
+var RUNTIME_CACHING = {{ .RuntimeCaching }};
+var RUNTIME_CACHE = '{{ js .RuntimeCacheName }}';
+
+// the maximum number of entries in, and age (in seconds) of the entries in,
+// each runtime cache keyed by cache name. Zero means no limit.
+
+var EXPIRATIONS = {
+	{{ range $name, $e := .Expirations }}'{{ js $name }}': { maxEntries: {{ $e.MaxEntries }}, maxAge: {{ $e.MaxAge }} },
+	{{ end }}
+};
+
+// the timestamps used to expire entries are stored in this database
+
+var EXPIRATIONS_DB = 'offline-expirations';
+
+// the documents (and images, fonts, etc.) to serve, keyed by request
+// destination, when a request can't be fulfilled by the network or the cache
+
+var FALLBACKS = {
+	{{ range $dest, $uri := .Fallbacks }}'{{ js $dest }}': '{{ js $uri }}',
+	{{ end }}
+};
+
+var ROUTES = [
+	{{ range $r := .Routes }}{ pattern: {{ if $r.Pattern }}new RegExp('{{ js $r.PatternRegexp }}'){{ else }}null{{ end }}, path: {{ $r.IsPathPattern }}, kind: '{{ $r.Kind }}', strategy: '{{ $r.Strategy }}', cache: '{{ js $r.CacheName }}', matchOptions: { ignoreSearch: {{ $r.IgnoreSearch }}, ignoreVary: {{ $r.IgnoreVary }} }, timeout: {{ $r.NetworkTimeout }} },
+	{{ end }}
+];
+
+var PRECACHE = [
+	{{ range $r := .Requests }}{ url: '{{ js $r.URL }}', kind: '{{ $r.Kind }}', revision: '{{ $r.Revision }}', mode: '{{ $r.Mode }}', credentials: '{{ $r.Credentials }}', opaque: {{ $r.Opaque }}, defer: {{ $r.Defer }} },
+	{{ end }}
+];
+
+// the absolute URLs of the precached items, which are always stored in CACHE
+
+var PRECACHED = {};
+
+PRECACHE.forEach(function (item) {
+  PRECACHED[new URL(item.url, self.location.href).href] = true;
+});
 
 self.addEventListener('install', function(evt) {
   console.log('The service worker is being installed.');
-  evt.waitUntil(precache());
+  evt.waitUntil(precache().then(function () {
+    // deferred items are cached on a best-effort basis and do not
+    // block installation
+    precacheDeferred();
+
+    if (SKIP_WAITING){
+      return self.skipWaiting();
+    }
+  }));
+});
+
+// delete the caches created by earlier versions of the service worker,
+// keeping the most recent KEEP_VERSIONS (including this one). Caches are
+// listed in the order they were created. CACHE_PREFIX is unique to the
+// service worker's scope so the caches of other service workers on the same
+// origin are left alone.
+
+self.addEventListener('activate', function(evt) {
+  console.log('The service worker is being activated.');
+  evt.waitUntil(caches.keys().then(function (names) {
+
+    var versions = names.filter(function (name) {
+      return name !== CACHE && name.indexOf(CACHE_PREFIX + '-') === 0;
+    });
+
+    var stale = versions.slice(0, Math.max(0, versions.length - (KEEP_VERSIONS - 1)));
+
+    // the unversioned cache for this scope, created before versioning was
+    // enabled, and the cache used before caches were scoped are always
+    // deleted
+
+    [ CACHE_PREFIX, LEGACY_CACHE ].forEach(function (name) {
+      if (name !== CACHE && names.indexOf(name) !== -1 && stale.indexOf(name) === -1){
+	stale.push(name);
+      }
+    });
+
+    return Promise.all(stale.map(function (name) {
+      console.log('Delete stale cache ' + name);
+      return caches.delete(name);
+    }));
+  }).then(function () {
+
+    // navigation preload starts fetching a page in parallel with the service
+    // worker booting up. It is turned off explicitly so that it doesn't stay
+    // on after being disabled in a later version.
+
+    var preload = self.registration.navigationPreload;
+
+    if (preload){
+      return (NAVIGATION_PRELOAD) ? preload.enable() : preload.disable();
+    }
+
+  }).then(function () {
+    if (CLIENTS_CLAIM){
+      return self.clients.claim();
+    }
+  }));
+});
+
+// pages can tell a waiting service worker to activate, without waiting for
+// every page using the current one to close, by posting it an
+// 'offline:skip-waiting' message. If the message has a port the service
+// worker replies on it once it has been told to activate.
+
+self.addEventListener('message', function(evt) {
+
+  if (! evt.data || evt.data.type !== 'offline:skip-waiting'){
+    return;
+  }
+
+  evt.waitUntil(self.skipWaiting().then(function () {
+    if (evt.ports && evt.ports[0]){
+      evt.ports[0].postMessage({ type: 'offline:skipped-waiting' });
+    }
+  }));
 });
 
 self.addEventListener('fetch', function(evt) {
+
+  // only GET requests can be stored in (and served from) the cache
+
+  if (evt.request.method !== 'GET'){
+    return;
+  }
+
+  var route = findRoute(evt.request);
+  route.preload = navigationPreload(evt);
+
+  // let the browser handle network-only requests as though there were no
+  // service worker at all, unless the response has already been preloaded
+
+  if (route.strategy === 'network-only' && ! route.preload){
+    return;
+  }
+
   console.log('The service worker is serving the asset.');
-  evt.respondWith(fromNetwork(evt.request, 400).catch(function () {
-    return fromCache(evt.request);
+  evt.respondWith(respond(evt, route).catch(function (reason) {
+    return fallback(evt.request, reason);
   }));
 });
 
-function precache() {
+// navigationPreload returns the preloaded response for a navigation request,
+// a promise which may resolve to undefined, or null if there isn't one
 
-  var cache_items = [
-	{{ range  $uri := .ToCache }}'{{ $uri }}',
-	{{ end }}
-  ];
+function navigationPreload(evt) {
+
+  if (! NAVIGATION_PRELOAD || evt.request.mode !== 'navigate' || ! evt.preloadResponse){
+    return null;
+  }
+
+  // keep the service worker alive until the preload settles, even if the
+  // response is served from the cache instead
+
+  evt.waitUntil(evt.preloadResponse.catch(function () {}));
+
+  return evt.preloadResponse;
+}
+
+function fallback(request, reason) {
+
+  var dest = (request.mode === 'navigate') ? 'document' : request.destination;
+  var uri = FALLBACKS[dest];
+
+  if (! uri){
+    return Promise.reject(reason);
+  }
+
+  return caches.match(uri).then(function (matching) {
+    if (! matching){
+      return Promise.reject(reason);
+    }
+    return matching;
+  });
+}
+
+// findRoute returns the options for the first route that matches request or
+// the service worker's default options
+
+function findRoute(request) {
+
+  var url = new URL(request.url);
+  var kind = requestKind(request);
+
+  for (var i = 0; i < ROUTES.length; i++){
+
+    var r = ROUTES[i];
+
+    if (r.kind && r.kind !== kind){
+      continue;
+    }
+
+    if (r.pattern){
+
+      if (r.path && url.origin !== self.location.origin){
+	continue;
+      }
+
+      var target = (r.path) ? url.pathname + url.search : url.href;
+
+      if (! r.pattern.test(target)){
+	continue;
+      }
+    }
+
+    return {
+      strategy: r.strategy,
+      cache: r.cache,
+      matchOptions: r.matchOptions,
+      timeout: r.timeout || NETWORK_TIMEOUT
+    };
+  }
+
+  return {
+    strategy: STRATEGY,
+    cache: '',
+    matchOptions: {},
+    timeout: NETWORK_TIMEOUT
+  };
+}
+
+// requestKind maps a request's destination on to the kinds of asset used by
+// routes: image, style, script, font, media, document or other
+
+function requestKind(request) {
+
+  if (request.mode === 'navigate'){
+    return 'document';
+  }
+
+  switch (request.destination) {
+    case 'image':
+      return 'image';
+    case 'style':
+      return 'style';
+    case 'script':
+    case 'worker':
+    case 'sharedworker':
+      return 'script';
+    case 'font':
+      return 'font';
+    case 'audio':
+    case 'video':
+    case 'track':
+      return 'media';
+    case 'document':
+    case 'iframe':
+    case 'frame':
+      return 'document';
+    default:
+      return 'other';
+  }
+}
+
+function respond(evt, route) {
+
+  switch (route.strategy) {
+    case 'cache-first':
+      return cacheFirst(evt.request, route);
+    case 'stale-while-revalidate':
+      return staleWhileRevalidate(evt, route);
+    case 'cache-only':
+      return fromCache(evt.request, route);
+    case 'network-only':
+      return fromNetwork(evt.request, route.preload);
+    default:
+      return networkFirst(evt.request, route);
+  }
+}
+
+function cacheFirst(request, route) {
+  return fromCache(request, route).catch(function () {
+    return fromNetwork(request, route.preload).then(function (response) {
+      return update(request, response, route);
+    });
+  });
+}
+
+// networkFirst falls back to the cache if the network request fails or takes
+// longer than route.timeout milliseconds (zero means wait indefinitely). If
+// the request timed out and isn't in the cache the network response is used
+// after all.
+
+function networkFirst(request, route) {
+
+  var network = fromNetwork(request, route.preload).then(function (response) {
+    return update(request, response, route);
+  });
 
-    return caches.open(CACHE).then(function (cache) {
-	
-    	return cache.addAll(cache_items);
-	
-	/*
-	return Promise.all(
-	    
-            cache_items.map(function (url) {
-		console.log("add URL ", url);
-		
-                return cache.add(url).catch(function (reason){
-                    return console.log(url + "failed: " + String(reason));
-                });
-                
-            });
-	);
-	*/
-
-    });
-}
-
-function fromNetwork(request, timeout) {
   return new Promise(function (fulfill, reject) {
-    var timeoutId = setTimeout(reject, timeout);
-    fetch(request).then(function (response) {
+
+    var fallingBack = false;
+
+    var fallback = function () {
+
+      if (fallingBack){
+	return;
+      }
+
+      fallingBack = true;
+
+      fromCache(request, route).then(fulfill, function () {
+	network.then(fulfill, reject);
+      });
+    };
+
+    var timeoutId = (route.timeout > 0) ? setTimeout(fallback, route.timeout) : null;
+
+    network.then(function (response) {
       clearTimeout(timeoutId);
       fulfill(response);
-    }, reject);
+    }, function () {
+      clearTimeout(timeoutId);
+      fallback();
+    });
   });
 }
 
-function fromCache(request) {
-  return caches.open(CACHE).then(function (cache) {
-    return cache.match(request).then(function (matching) {
-      if (! matching){
-	return Promise.reject('no-match');
+function staleWhileRevalidate(evt, route) {
+
+  var request = evt.request;
+
+  var revalidate = fromNetwork(request, route.preload).then(function (response) {
+    return update(request, response, route);
+  });
+
+  // keep the service worker alive until the cache has been updated
+
+  evt.waitUntil(revalidate.catch(function () {}));
+
+  return fromCache(request, route).catch(function () {
+    return revalidate;
+  });
+}
+
+// update stores a copy of response, if it was successful, in the cache
+// returned by cacheFor and returns response
+
+function update(request, response, route) {
+
+  var name = cacheFor(request, route);
+
+  if (! response.ok || ! name){
+    return response;
+  }
+
+  var copy = response.clone();
+
+  return caches.open(name).then(function (cache) {
+    return cache.put(request, copy);
+  }).then(function () {
+
+    // expiring old entries happens in the background and does not delay
+    // the response
+
+    touch(name, request.url, true).then(function () {
+      return expire(name);
+    }).catch(function (reason) {
+      console.log('Failed to expire entries in ' + name + ': ' + String(reason));
+    });
+
+    return response;
+  }, function () {
+    return response;
+  });
+}
+
+// cacheFor returns the name of the cache that the response for request
+// should be stored in or an empty string if it should not be stored
+
+function cacheFor(request, route) {
+
+  if (PRECACHED[request.url]){
+    return CACHE;
+  }
+
+  if (route.cache){
+    return route.cache;
+  }
+
+  return (RUNTIME_CACHING) ? RUNTIME_CACHE : '';
+}
+
+var expirations_db = null;
+
+function openExpirations() {
+
+  if (! expirations_db){
+
+    expirations_db = new Promise(function (resolve, reject) {
+
+      var req = indexedDB.open(EXPIRATIONS_DB, 1);
+
+      req.onupgradeneeded = function () {
+	var store = req.result.createObjectStore('entries', { keyPath: 'id' });
+	store.createIndex('timestamp', ['cache', 'timestamp']);
+      };
+
+      req.onsuccess = function () {
+	resolve(req.result);
+      };
+
+      req.onerror = function () {
+	reject(req.error);
+      };
+    });
+
+    expirations_db.catch(function () {
+      expirations_db = null;
+    });
+  }
+
+  return expirations_db;
+}
+
+// touch records that url, in the cache name, was just used. If stored is
+// true it was also just (re)stored. Entries are evicted in the order they
+// were last used and expire a fixed time after they were stored.
+
+function touch(name, url, stored) {
+
+  if (! EXPIRATIONS[name]){
+    return Promise.resolve();
+  }
+
+  return openExpirations().then(function (db) {
+    return new Promise(function (resolve, reject) {
+
+      var tx = db.transaction('entries', 'readwrite');
+      var store = tx.objectStore('entries');
+      var id = name + ' ' + url;
+      var now = Date.now();
+
+      var get = store.get(id);
+
+      get.onsuccess = function () {
+
+	var entry = get.result || { id: id, cache: name, url: url, stored: now };
+
+	if (stored){
+	  entry.stored = now;
+	}
+
+	entry.timestamp = now;
+	store.put(entry);
+      };
+
+      tx.oncomplete = function () {
+	resolve();
+      };
+
+      tx.onerror = function () {
+	reject(tx.error);
+      };
+    });
+  });
+}
+
+// isExpired resolves true if url, in the cache name, is older than the
+// cache's maximum age
+
+function isExpired(name, url) {
+
+  var max_age = EXPIRATIONS[name].maxAge;
+
+  if (! max_age){
+    return Promise.resolve(false);
+  }
+
+  return openExpirations().then(function (db) {
+    return new Promise(function (resolve, reject) {
+
+      var get = db.transaction('entries').objectStore('entries').get(name + ' ' + url);
+
+      get.onsuccess = function () {
+	var entry = get.result;
+	resolve(entry ? (Date.now() - entry.stored > max_age * 1000) : false);
+      };
+
+      get.onerror = function () {
+	reject(get.error);
+      };
+    });
+  }).catch(function () {
+    return false;
+  });
+}
+
+// expire deletes the entries in the cache name that are older than the
+// cache's maximum age and then, least recently used first, those beyond its
+// maximum number of entries
+
+function expire(name) {
+
+  var limits = EXPIRATIONS[name];
+
+  if (! limits){
+    return Promise.resolve();
+  }
+
+  return openExpirations().then(function (db) {
+    return new Promise(function (resolve, reject) {
+
+      var tx = db.transaction('entries', 'readwrite');
+      var range = IDBKeyRange.bound([name, 0], [name, Infinity]);
+      var now = Date.now();
+      var kept = 0;
+      var stale = [];
+
+      // most recently used first
+
+      var cursor = tx.objectStore('entries').index('timestamp').openCursor(range, 'prev');
+
+      cursor.onsuccess = function () {
+
+	var c = cursor.result;
+
+	if (! c){
+	  return;
+	}
+
+	var entry = c.value;
+
+	if (limits.maxAge && now - entry.stored > limits.maxAge * 1000){
+	  stale.push(entry.url);
+	  c.delete();
+	} else if (limits.maxEntries && kept >= limits.maxEntries){
+	  stale.push(entry.url);
+	  c.delete();
+	} else {
+	  kept += 1;
+	}
+
+	c.continue();
+      };
+
+      tx.oncomplete = function () {
+	resolve(stale);
+      };
+
+      tx.onerror = function () {
+	reject(tx.error);
+      };
+    });
+  }).then(function (stale) {
+
+    if (! stale.length){
+      return;
+    }
+
+    console.log('Expire ' + stale.length + ' entries in ' + name);
+
+    return caches.open(name).then(function (cache) {
+      return Promise.all(stale.map(function (url) {
+	return cache.delete(url);
+      }));
+    });
+  });
+}
+
+function toRequest(item) {
+  return new Request(item.url, { mode: item.mode, credentials: item.credentials });
+}
+
+function precache() {
+
+  var items = PRECACHE.filter(function (item) {
+    return ! item.defer;
+  });
+
+  // opaque (no-cors) responses are always rejected by cache.addAll so
+  // they need to be fetched and stored separately
+
+  var opaque_items = items.filter(function (item) {
+    return item.opaque;
+  });
+
+  var failures = [];
+
+    return caches.open(CACHE).then(function (cache) {
+
+      return previousRevisions().then(function (previous) {
+
+	// items whose revision hasn't changed since the last time they were
+	// precached are copied from the previous cache rather than fetched
+
+	var cache_items = [];
+	var copy_items = [];
+
+	items.forEach(function (item) {
+
+	  if (item.opaque){
+	    return;
+	  }
+
+	  if (item.revision && previous.revisions[item.url] === item.revision){
+	    copy_items.push(item);
+	  } else {
+	    cache_items.push(toRequest(item));
+	  }
+	});
+
+	console.log('Precache ' + cache_items.length + ' items, reuse ' + copy_items.length + ' items');
+
+	return Promise.all(copy_items.map(function (item) {
+	  var req = toRequest(item);
+
+	  return previous.cache.match(req).then(function (response) {
+
+	    if (! response){
+	      cache_items.push(req);
+	      return;
+	    }
+
+	    if (previous.name === CACHE){
+	      return;
+	    }
+
+	    return cache.put(req, response);
+	  });
+
+	})).then(function () {
+	  return precacheAll(cache, cache_items, false);
+
+	}).then(function (failed) {
+	  failures = failures.concat(failed);
+	  return precacheAll(cache, opaque_items.map(toRequest), true);
+
+	}).then(function (failed) {
+
+	  failures = failures.concat(failed);
+
+	  var cached = items.filter(function (item) {
+	    return ! failures.some(function (f) {
+	      return f.url === toRequest(item).url;
+	    });
+	  });
+
+	  return cache.put(REVISIONS, revisionsResponse(cached));
+
+	}).then(function () {
+	  return reportFailures(failures);
+	});
+      });
+    });
+}
+
+// precacheAll adds requests to cache and resolves with the list of requests
+// that failed. In strict mode adding requests is atomic: a single failure
+// rejects (and fails the install). In tolerant mode each request is added
+// separately, and retried with exponential backoff, so that the install
+// succeeds regardless.
+
+function precacheAll(cache, requests, opaque) {
+
+  var add = function (req) {
+
+    if (! opaque){
+      return cache.add(req);
+    }
+
+    return fetch(req).then(function (response) {
+      return cache.put(req, response);
+    });
+  };
+
+  if (PRECACHE_MODE !== 'tolerant'){
+
+    var added = (opaque) ? Promise.all(requests.map(add)) : cache.addAll(requests);
+
+    return added.then(function () {
+      return [];
+    });
+  }
+
+  var failed = [];
+
+  return Promise.all(requests.map(function (req) {
+
+    return withRetry(function () {
+      return add(req);
+    }, 0).catch(function (reason) {
+      console.log('Failed to precache ' + req.url + ': ' + String(reason));
+      failed.push({ url: req.url, reason: String(reason) });
+    });
+
+  })).then(function () {
+    return failed;
+  });
+}
+
+function withRetry(fn, attempt) {
+  return fn().catch(function (reason) {
+
+    if (attempt >= PRECACHE_RETRIES){
+      return Promise.reject(reason);
+    }
+
+    var delay = PRECACHE_RETRY_DELAY * Math.pow(2, attempt);
+
+    return new Promise(function (resolve) {
+      setTimeout(resolve, delay);
+    }).then(function () {
+      return withRetry(fn, attempt + 1);
+    });
+  });
+}
+
+// reportFailures posts the list of items that could not be precached to
+// every open window
+
+function reportFailures(failures) {
+
+  if (! failures.length){
+    return;
+  }
+
+  return self.clients.matchAll({ includeUncontrolled: true, type: 'window' }).then(function (clients) {
+    clients.forEach(function (client) {
+      client.postMessage({ type: 'offline:precache-failed', cache: CACHE, failures: failures });
+    });
+  });
+}
+
+// previousRevisions returns the cache (and the revisions of the items it
+// contains) that the service worker was most recently precached in, looking
+// at this version's cache first and then the caches of earlier versions
+
+function previousRevisions() {
+
+  var none = { name: null, cache: null, revisions: {} };
+
+  return caches.keys().then(function (names) {
+
+    var versions = names.filter(function (name) {
+      return name !== CACHE && (name === CACHE_PREFIX || name === LEGACY_CACHE || name.indexOf(CACHE_PREFIX + '-') === 0);
+    }).reverse();
+
+    if (names.indexOf(CACHE) !== -1){
+      versions.unshift(CACHE);
+    }
+
+    var find = function (idx) {
+
+      if (idx >= versions.length){
+	return none;
       }
-      return matching;
+
+      var name = versions[idx];
+
+      return caches.open(name).then(function (cache) {
+	return cache.match(REVISIONS).then(function (response) {
+
+	  if (! response){
+	    return find(idx + 1);
+	  }
+
+	  return response.json().then(function (revisions) {
+	    return { name: name, cache: cache, revisions: revisions };
+	  });
+	});
+      });
+    };
+
+    return find(0);
+
+  }).catch(function () {
+    return none;
+  });
+}
+
+function revisionsResponse(items) {
+
+  var revisions = {};
+
+  items.forEach(function (item) {
+
+    if (item.revision){
+      revisions[item.url] = item.revision;
+    }
+  });
+
+  return new Response(JSON.stringify(revisions), { headers: { 'Content-Type': 'application/json' } });
+}
+
+function precacheDeferred() {
+
+  var items = PRECACHE.filter(function (item) {
+    return item.defer;
+  });
+
+  return caches.open(CACHE).then(function (cache) {
+
+    return Promise.all(items.map(function (item) {
+      var req = toRequest(item);
+
+      return fetch(req).then(function (response) {
+
+	if (! item.opaque && ! response.ok){
+	  throw new Error(response.status);
+	}
+
+	return cache.put(req, response);
+
+      }).catch(function (reason) {
+	console.log('Failed to cache ' + item.url + ': ' + String(reason));
+      });
+    }));
+  });
+}
+
+// if preload, a navigation preload response, is not null it is used instead
+// of fetching request unless it resolves to undefined or fails.
+
+function fromNetwork(request, preload) {
+
+  if (! preload){
+    return fetch(request);
+  }
+
+  return preload.then(function (preloaded) {
+    return preloaded || fetch(request);
+  }, function () {
+    return fetch(request);
+  });
+}
+
+// fromCache looks for request in the route's cache, the service worker's
+// cache and the runtime cache, in that order, and then in any cache. Expired
+// entries are deleted rather than returned.
+
+function fromCache(request, route) {
+
+  var names = [ route.cache, CACHE, RUNTIME_CACHE ].filter(function (name, idx, all) {
+    return name && all.indexOf(name) === idx;
+  });
+
+  var find = function (idx) {
+
+    if (idx >= names.length){
+      return caches.match(request, route.matchOptions);
+    }
+
+    var name = names[idx];
+
+    return caches.open(name).then(function (cache) {
+      return cache.match(request, route.matchOptions).then(function (matching) {
+
+	if (! matching){
+	  return find(idx + 1);
+	}
+
+	if (! EXPIRATIONS[name]){
+	  return matching;
+	}
+
+	return isExpired(name, request.url).then(function (expired) {
+
+	  if (expired){
+	    return cache.delete(request, route.matchOptions).then(function () {
+	      return find(idx + 1);
+	    });
+	  }
+
+	  touch(name, request.url, false).catch(function () {});
+	  return matching;
+	});
+      });
     });
+  };
+
+  return find(0).then(function (matching) {
+    if (! matching){
+      return Promise.reject('no-match');
+    }
+    return matching;
   });
 }`
 
@@ -78,10 +919,71 @@ function fromCache(request) {
 // this code was added by robots on {{ .Date }}
 // https://github.com/sfomuseum/go-html-offline
 
+// when a new version of the service worker has been installed, and is
+// waiting to replace the one controlling this page, an
+// 'offline:update-available' event is dispatched on window. Calling the
+// event's detail.activate() method tells the new service worker to activate
+// and resolves once it controls the page, at which point the page can be
+// reloaded. For example:
+//
+// window.addEventListener('offline:update-available', function(event){
+//   if (confirm('A new version is available. Reload?')){
+//     event.detail.activate().then(function(){ location.reload(); });
+//   }
+// });
+
 window.addEventListener("load", function load(event){
+
+  var offlineUpdateAvailable = function(registration, worker){
+
+    var activate = function(){
+      return new Promise(function(resolve){
+
+	navigator.serviceWorker.addEventListener('controllerchange', function(){
+	  resolve();
+	}, { once: true });
+
+	var channel = new MessageChannel();
+
+	channel.port1.onmessage = function(){
+	  console.log('The new service worker is activating.');
+	};
+
+	worker.postMessage({ type: 'offline:skip-waiting' }, [ channel.port2 ]);
+      });
+    };
+
+    console.log('A new version of the service worker is available.');
+
+    window.dispatchEvent(new CustomEvent('offline:update-available', {
+      detail: { registration: registration, worker: worker, activate: activate }
+    }));
+  };
+
 if ('serviceWorker' in navigator) {
   navigator.serviceWorker.register('{{ .ServiceWorkerURL }}').then(function(registration) {
     console.log('Service worker registration succeeded:', registration);
+
+    // there is nothing to update the very first time a service worker is
+    // installed, which is the case when the page has no controller yet. The
+    // updatefound listener is registered regardless since the first service
+    // worker may claim the page before an update is installed.
+
+    if (registration.waiting && navigator.serviceWorker.controller){
+      offlineUpdateAvailable(registration, registration.waiting);
+    }
+
+    registration.addEventListener('updatefound', function(){
+
+      var worker = registration.installing;
+
+      worker.addEventListener('statechange', function(){
+	if (worker.state === 'installed' && navigator.serviceWorker.controller){
+	  offlineUpdateAvailable(registration, worker);
+	}
+      });
+    });
+
   }, /*catch*/ function(error) {
     console.log('Service worker registration failed:', error);
   });
@@ -0,0 +1,205 @@
+package offline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// TemplateSource is a user-supplied template for the service worker or the
+// script that registers it. If Text is set it is used as-is, otherwise the
+// template is read from Path which is relative to FS, if set, or the local
+// filesystem.
+type TemplateSource struct {
+	Text string
+	Path string
+	FS   fs.FS
+}
+
+func NewTemplateFromString(text string) *TemplateSource {
+	return &TemplateSource{Text: text}
+}
+
+func NewTemplateFromFile(path string) *TemplateSource {
+	return &TemplateSource{Path: path}
+}
+
+func NewTemplateFromFS(fsys fs.FS, path string) *TemplateSource {
+	return &TemplateSource{Path: path, FS: fsys}
+}
+
+// Read returns the body of the template.
+func (s *TemplateSource) Read() (string, error) {
+
+	if s.Text != "" {
+		return s.Text, nil
+	}
+
+	if s.Path == "" {
+		return "", fmt.Errorf("Template source is missing text or path")
+	}
+
+	var body []byte
+	var err error
+
+	if s.FS != nil {
+		body, err = fs.ReadFile(s.FS, s.Path)
+	} else {
+		body, err = ioutil.ReadFile(s.Path)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// TemplateFuncs returns the helper functions available to service worker and
+// registration templates:
+//
+//	json       the JSON encoding of a value, safe to use as a JavaScript literal
+//	join       strings.Join
+//	lower      strings.ToLower
+//	upper      strings.ToUpper
+//	replace    strings.ReplaceAll
+//	default    the first argument if the second is empty
+//	assetsOf   the assets (from .Assets) of a given kind
+//	urls       the URLs of a list of assets
+func TemplateFuncs() template.FuncMap {
+
+	return template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+
+			enc, err := json.Marshal(v)
+
+			if err != nil {
+				return "", err
+			}
+
+			return string(enc), nil
+		},
+		"join":    strings.Join,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"replace": strings.ReplaceAll,
+		"default": func(def interface{}, v interface{}) interface{} {
+
+			if v == nil || v == "" || v == 0 || v == false {
+				return def
+			}
+
+			return v
+		},
+		"assetsOf": func(kind string, assets []*Asset) []*Asset {
+
+			matches := make([]*Asset, 0)
+
+			for _, a := range assets {
+
+				if a.Kind == kind {
+					matches = append(matches, a)
+				}
+			}
+
+			return matches
+		},
+		"urls": func(assets []*Asset) []string {
+
+			urls := make([]string, len(assets))
+
+			for idx, a := range assets {
+				urls[idx] = a.URL
+			}
+
+			return urls
+		},
+	}
+}
+
+// ValidateTemplates parses the service worker and registration templates
+// defined by opts and executes them with empty variables, returning the first
+// error encountered. Use it to check custom templates before any files are
+// written. The parsed templates are kept and reused for every document
+// processed with opts so ValidateTemplates needs to be called again if the
+// ServiceWorkerTemplate or InitTemplate properties change.
+func ValidateTemplates(opts *ServiceWorkerOptions) error {
+
+	sw_t, init_t, err := parseTemplates(opts)
+
+	if err != nil {
+		return err
+	}
+
+	err = sw_t.Execute(ioutil.Discard, ServiceWorkerVars{Data: opts.TemplateData})
+
+	if err != nil {
+		return err
+	}
+
+	err = init_t.Execute(ioutil.Discard, ServiceWorkerInitVars{Data: opts.TemplateData})
+
+	if err != nil {
+		return err
+	}
+
+	opts.sw_template = sw_t
+	opts.init_template = init_t
+
+	return nil
+}
+
+// templates returns the templates parsed by ValidateTemplates or, if it
+// hasn't been called, parses them.
+func (opts *ServiceWorkerOptions) templates() (*template.Template, *template.Template, error) {
+
+	if opts.sw_template != nil && opts.init_template != nil {
+		return opts.sw_template, opts.init_template, nil
+	}
+
+	return parseTemplates(opts)
+}
+
+func parseTemplates(opts *ServiceWorkerOptions) (*template.Template, *template.Template, error) {
+
+	sw_t, err := parseTemplate("service-worker", sw, opts.ServiceWorkerTemplate)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	init_t, err := parseTemplate("service-worker-init", sw_init, opts.InitTemplate)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sw_t, init_t, nil
+}
+
+func parseTemplate(name string, default_text string, source *TemplateSource) (*template.Template, error) {
+
+	text := default_text
+
+	if source != nil {
+
+		custom_text, err := source.Read()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s template, %v", name, err)
+		}
+
+		text = custom_text
+	}
+
+	t, err := template.New(name).Funcs(TemplateFuncs()).Parse(text)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s template, %v", name, err)
+	}
+
+	return t, nil
+}
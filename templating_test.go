@@ -0,0 +1,87 @@
+package offline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplates(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "offline")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sw.js.tmpl")
+
+	err = ioutil.WriteFile(path, []byte("var CACHE = '{{ js .CacheName }}'; // {{ .Data.greeting }}"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source *TemplateSource
+		ok     bool
+	}{
+		{nil, true},
+		{NewTemplateFromString("var CACHE = '{{ js .CacheName }}';"), true},
+		{NewTemplateFromFile(path), true},
+		{NewTemplateFromString("{{ .CacheName "), false},
+		{NewTemplateFromString("{{ .NoSuchVariable }}"), false},
+		{NewTemplateFromFile(filepath.Join(dir, "missing.tmpl")), false},
+	}
+
+	for idx, test := range tests {
+
+		opts := DefaultServiceWorkerOptions()
+		opts.ServiceWorkerTemplate = test.source
+
+		err := ValidateTemplates(opts)
+
+		if test.ok && err != nil {
+			t.Fatalf("Expected template %d to validate, %v", idx, err)
+		}
+
+		if !test.ok && err == nil {
+			t.Fatalf("Expected template %d to fail validation", idx)
+		}
+	}
+
+	// templates are parsed once so template files aren't read again
+
+	opts := DefaultServiceWorkerOptions()
+	opts.ServiceWorkerTemplate = NewTemplateFromFile(path)
+	opts.TemplateData = map[string]interface{}{"greeting": "hello"}
+
+	err = ValidateTemplates(opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sw bytes.Buffer
+
+	err = AddServiceWorker(strings.NewReader("<html><head></head></html>"), ioutil.Discard, &sw, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(sw.String(), "// hello") {
+		t.Fatalf("Expected service worker to be rendered using the custom template, got '%s'", sw.String())
+	}
+}